@@ -0,0 +1,38 @@
+package config
+
+import (
+	"github.com/Debsnil24/URL_Shortner.git/util"
+	"gorm.io/gorm"
+)
+
+// destinationKeyBatchSize is the number of urls rows updated per query round
+const destinationKeyBatchSize = 1000
+
+// backfillDestinationKeys computes urls.destination_key for rows created before the column existed
+func backfillDestinationKeys(tx *gorm.DB) error {
+	var lastID uint
+	for {
+		var rows []struct {
+			ID          uint
+			OriginalURL string
+		}
+		if err := tx.Table("urls").
+			Select("id, original_url").
+			Where("id > ? AND destination_key IS NULL", lastID).
+			Order("id").
+			Limit(destinationKeyBatchSize).
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+			if err := tx.Table("urls").Where("id = ?", row.ID).Update("destination_key", util.DestinationKey(row.OriginalURL)).Error; err != nil {
+				return err
+			}
+		}
+	}
+}
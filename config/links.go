@@ -0,0 +1,94 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/util"
+)
+
+// LinkExpiryPolicy holds the server-enforced bounds on link lifetimes
+var LinkExpiryPolicy = util.ExpiryPolicy{
+	Default:    5 * 365 * 24 * time.Hour,
+	Min:        time.Minute,
+	AllowNever: true,
+}
+
+// DestinationPolicy normalises and validates the destinations of new and edited links
+var DestinationPolicy = util.URLPolicy{
+	MaxLength:    2048,
+	BlockedHosts: []string{"sniply.co.in"},
+}
+
+// PermanentRedirectMaxAge bounds how long a browser may cache a 301 or 308 redirect; clicks
+// served from a cached redirect never reach us, are not counted and keep going to the destination
+// even after the link is blocklisted or deleted. Zero disables caching
+var PermanentRedirectMaxAge = 5 * time.Minute
+
+// InitLinkPolicy reads link lifetime limits and destination rules from the environment
+func InitLinkPolicy() {
+	LinkExpiryPolicy.Default = durationFromEnv("LINK_DEFAULT_TTL", LinkExpiryPolicy.Default)
+	LinkExpiryPolicy.Min = durationFromEnv("LINK_MIN_TTL", LinkExpiryPolicy.Min)
+	LinkExpiryPolicy.Max = durationFromEnv("LINK_MAX_TTL", LinkExpiryPolicy.Max)
+
+	if value := os.Getenv("LINK_ALLOW_NEVER_EXPIRE"); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			LinkExpiryPolicy.AllowNever = parsed
+		} else {
+			log.Printf("Invalid LINK_ALLOW_NEVER_EXPIRE %q, keeping %t", value, LinkExpiryPolicy.AllowNever)
+		}
+	}
+
+	if LinkExpiryPolicy.Max > 0 && LinkExpiryPolicy.Min > LinkExpiryPolicy.Max {
+		log.Printf("LINK_MIN_TTL %s is above LINK_MAX_TTL %s, using %s", util.FormatTTL(LinkExpiryPolicy.Min), util.FormatTTL(LinkExpiryPolicy.Max), util.FormatTTL(LinkExpiryPolicy.Max))
+		LinkExpiryPolicy.Min = LinkExpiryPolicy.Max
+	}
+	if LinkExpiryPolicy.DefaultLifetime() != LinkExpiryPolicy.Default {
+		log.Printf("LINK_DEFAULT_TTL %s is outside LINK_MIN_TTL/LINK_MAX_TTL, using %s", util.FormatTTL(LinkExpiryPolicy.Default), util.FormatTTL(LinkExpiryPolicy.DefaultLifetime()))
+		LinkExpiryPolicy.Default = LinkExpiryPolicy.DefaultLifetime()
+	}
+
+	if value := os.Getenv("URL_MAX_LENGTH"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			DestinationPolicy.MaxLength = parsed
+		} else {
+			log.Printf("Invalid URL_MAX_LENGTH %q, keeping %d", value, DestinationPolicy.MaxLength)
+		}
+	}
+
+	// Destinations on these domains would send visitors straight back to another short link
+	if value := os.Getenv("SHORT_LINK_DOMAINS"); value != "" {
+		domains := make([]string, 0)
+		for _, domain := range strings.Split(value, ",") {
+			domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+			if domain != "" {
+				domains = append(domains, domain)
+			}
+		}
+		DestinationPolicy.BlockedHosts = domains
+	}
+
+	if value := os.Getenv("REDIRECT_PERMANENT_MAX_AGE"); value == "0" {
+		PermanentRedirectMaxAge = 0
+	} else {
+		PermanentRedirectMaxAge = durationFromEnv("REDIRECT_PERMANENT_MAX_AGE", PermanentRedirectMaxAge)
+	}
+}
+
+// durationFromEnv parses a TTL-style env var, falling back to the default when unset or invalid
+func durationFromEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := util.ParseTTL(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %s", key, value, util.FormatTTL(defaultValue))
+		return defaultValue
+	}
+	return parsed
+}
@@ -115,5 +115,326 @@ func GetMigrations() []*gormigrate.Migration {
 				`).Error
 			},
 		},
+		{
+			ID: "20261016_url_max_clicks_column",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE urls
+					ADD COLUMN IF NOT EXISTS max_clicks INTEGER
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE urls
+					DROP COLUMN IF EXISTS max_clicks
+				`).Error
+			},
+		},
+		{
+			ID: "20261016_url_password_hash_column",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE urls
+					ADD COLUMN IF NOT EXISTS password_hash TEXT
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE urls
+					DROP COLUMN IF EXISTS password_hash
+				`).Error
+			},
+		},
+		{
+			ID: "20261016_url_visit_referrer_columns",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE url_visits
+					ADD COLUMN IF NOT EXISTS referrer TEXT,
+					ADD COLUMN IF NOT EXISTS referrer_domain VARCHAR(255)
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE url_visits
+					DROP COLUMN IF EXISTS referrer,
+					DROP COLUMN IF EXISTS referrer_domain
+				`).Error
+			},
+		},
+		{
+			ID: "20261016_url_visit_user_agent_columns",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE url_visits
+					ADD COLUMN IF NOT EXISTS browser VARCHAR(50),
+					ADD COLUMN IF NOT EXISTS os VARCHAR(50),
+					ADD COLUMN IF NOT EXISTS device_type VARCHAR(20),
+					ADD COLUMN IF NOT EXISTS is_bot BOOLEAN DEFAULT FALSE
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE url_visits
+					DROP COLUMN IF EXISTS browser,
+					DROP COLUMN IF EXISTS os,
+					DROP COLUMN IF EXISTS device_type,
+					DROP COLUMN IF EXISTS is_bot
+				`).Error
+			},
+		},
+		{
+			ID: "20261016_url_visit_geo_columns",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE url_visits
+					ADD COLUMN IF NOT EXISTS country VARCHAR(2),
+					ADD COLUMN IF NOT EXISTS region VARCHAR(100),
+					ADD COLUMN IF NOT EXISTS city VARCHAR(100)
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE url_visits
+					DROP COLUMN IF EXISTS country,
+					DROP COLUMN IF EXISTS region,
+					DROP COLUMN IF EXISTS city
+				`).Error
+			},
+		},
+		{
+			ID: "20261016_url_visit_daily_rollups",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					CREATE TABLE IF NOT EXISTS url_daily_stats (
+						id BIGSERIAL PRIMARY KEY,
+						url_id BIGINT NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+						day DATE NOT NULL,
+						total_visits BIGINT NOT NULL DEFAULT 0,
+						clicks BIGINT NOT NULL DEFAULT 0,
+						bot_visits BIGINT NOT NULL DEFAULT 0,
+						unique_visitors BIGINT NOT NULL DEFAULT 0,
+						last_visit_at TIMESTAMPTZ,
+						last_user_agent TEXT
+					);
+					CREATE UNIQUE INDEX IF NOT EXISTS idx_url_daily_stats_url_day ON url_daily_stats(url_id, day);
+					CREATE TABLE IF NOT EXISTS url_daily_breakdowns (
+						id BIGSERIAL PRIMARY KEY,
+						url_id BIGINT NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+						day DATE NOT NULL,
+						dimension VARCHAR(20) NOT NULL,
+						value TEXT NOT NULL DEFAULT '',
+						visits BIGINT NOT NULL DEFAULT 0
+					);
+					CREATE UNIQUE INDEX IF NOT EXISTS idx_url_daily_breakdowns_key ON url_daily_breakdowns(url_id, day, dimension, value);
+					CREATE INDEX IF NOT EXISTS idx_url_visits_created_at ON url_visits(created_at);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					DROP INDEX IF EXISTS idx_url_visits_created_at;
+					DROP TABLE IF EXISTS url_daily_breakdowns;
+					DROP TABLE IF EXISTS url_daily_stats;
+				`).Error
+			},
+		},
+		{
+			ID: "20261016_url_visit_listing_indexes",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					CREATE INDEX IF NOT EXISTS idx_url_visits_url_id_created_at ON url_visits(url_id, created_at);
+					CREATE INDEX IF NOT EXISTS idx_urls_user_id_created_at ON urls(user_id, created_at);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					DROP INDEX IF EXISTS idx_url_visits_url_id_created_at;
+					DROP INDEX IF EXISTS idx_urls_user_id_created_at;
+				`).Error
+			},
+		},
+		{
+			ID: "20261016_url_title_and_search_indexes",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).Error; err != nil {
+					return err
+				}
+
+				return tx.Exec(`
+					ALTER TABLE urls ADD COLUMN IF NOT EXISTS title TEXT;
+					CREATE INDEX IF NOT EXISTS idx_urls_short_code_trgm ON urls USING gin (short_code gin_trgm_ops);
+					CREATE INDEX IF NOT EXISTS idx_urls_original_url_trgm ON urls USING gin (original_url gin_trgm_ops);
+					CREATE INDEX IF NOT EXISTS idx_urls_title_trgm ON urls USING gin (title gin_trgm_ops);
+					CREATE INDEX IF NOT EXISTS idx_urls_search_document ON urls
+						USING gin (to_tsvector('simple', COALESCE(title, '') || ' ' || original_url));
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					DROP INDEX IF EXISTS idx_urls_search_document;
+					DROP INDEX IF EXISTS idx_urls_title_trgm;
+					DROP INDEX IF EXISTS idx_urls_original_url_trgm;
+					DROP INDEX IF EXISTS idx_urls_short_code_trgm;
+					ALTER TABLE urls DROP COLUMN IF EXISTS title;
+				`).Error
+			},
+		},
+		{
+			ID: "20261016_tags_and_folders",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					CREATE TABLE IF NOT EXISTS tags (
+						id BIGSERIAL PRIMARY KEY,
+						user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+						name VARCHAR(50) NOT NULL,
+						created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+					);
+					CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_user_name ON tags(user_id, lower(name));
+					CREATE TABLE IF NOT EXISTS url_tags (
+						url_id BIGINT NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+						tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+						PRIMARY KEY (url_id, tag_id)
+					);
+					CREATE INDEX IF NOT EXISTS idx_url_tags_tag_id ON url_tags(tag_id);
+					CREATE TABLE IF NOT EXISTS folders (
+						id BIGSERIAL PRIMARY KEY,
+						user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+						name VARCHAR(100) NOT NULL,
+						created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+					);
+					CREATE UNIQUE INDEX IF NOT EXISTS idx_folders_user_name ON folders(user_id, lower(name));
+					ALTER TABLE urls ADD COLUMN IF NOT EXISTS folder_id BIGINT REFERENCES folders(id) ON DELETE SET NULL;
+					CREATE INDEX IF NOT EXISTS idx_urls_folder_id ON urls(folder_id);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE urls DROP COLUMN IF EXISTS folder_id;
+					DROP TABLE IF EXISTS folders;
+					DROP TABLE IF EXISTS url_tags;
+					DROP TABLE IF EXISTS tags;
+				`).Error
+			},
+		},
+		{
+			ID: "20261016_idempotent_shortening",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec(`
+					ALTER TABLE users ADD COLUMN IF NOT EXISTS reuse_existing_links BOOLEAN NOT NULL DEFAULT FALSE;
+					ALTER TABLE urls ADD COLUMN IF NOT EXISTS destination_key TEXT;
+					CREATE TABLE IF NOT EXISTS idempotency_keys (
+						id BIGSERIAL PRIMARY KEY,
+						user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+						key VARCHAR(255) NOT NULL,
+						fingerprint VARCHAR(64) NOT NULL,
+						url_id BIGINT REFERENCES urls(id) ON DELETE CASCADE,
+						created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+					);
+					CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_keys_user_key ON idempotency_keys(user_id, key);
+				`).Error; err != nil {
+					return err
+				}
+
+				if err := backfillDestinationKeys(tx); err != nil {
+					return err
+				}
+
+				return tx.Exec(`
+					CREATE INDEX IF NOT EXISTS idx_urls_user_id_destination_key ON urls(user_id, destination_key);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					DROP TABLE IF EXISTS idempotency_keys;
+					DROP INDEX IF EXISTS idx_urls_user_id_destination_key;
+					ALTER TABLE urls DROP COLUMN IF EXISTS destination_key;
+					ALTER TABLE users DROP COLUMN IF EXISTS reuse_existing_links;
+				`).Error
+			},
+		},
+		{
+			ID: "20261016_url_blocklist_columns",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE urls ADD COLUMN IF NOT EXISTS blocked_reason TEXT NOT NULL DEFAULT '';
+					ALTER TABLE urls ADD COLUMN IF NOT EXISTS blocked_at TIMESTAMPTZ;
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE urls DROP COLUMN IF EXISTS blocked_at;
+					ALTER TABLE urls DROP COLUMN IF EXISTS blocked_reason;
+				`).Error
+			},
+		},
+		{
+			ID: "20261016_redirect_status",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE users ADD COLUMN IF NOT EXISTS default_redirect_status SMALLINT NOT NULL DEFAULT 302;
+					ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect_status SMALLINT NOT NULL DEFAULT 302;
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE urls DROP COLUMN IF EXISTS redirect_status;
+					ALTER TABLE users DROP COLUMN IF EXISTS default_redirect_status;
+				`).Error
+			},
+		},
+		{
+			ID: "20261016_ip_hash_salts",
+			Migrate: func(tx *gorm.DB) error {
+				// One random salt per UTC day for IP_PRIVACY_MODE=hash, shared by every instance
+				return tx.Exec(`
+					CREATE TABLE IF NOT EXISTS ip_hash_salts (
+						day DATE PRIMARY KEY,
+						salt BYTEA NOT NULL,
+						created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+					)
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`DROP TABLE IF EXISTS ip_hash_salts`).Error
+			},
+		},
+		{
+			ID: "20261016_renormalise_destination_keys",
+			Migrate: func(tx *gorm.DB) error {
+				// Destination keys now follow the URL policy's normalisation; recompute the old ones
+				if err := tx.Exec(`UPDATE urls SET destination_key = NULL`).Error; err != nil {
+					return err
+				}
+				return backfillDestinationKeys(tx)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return nil
+			},
+		},
+		{
+			ID: "20261016_url_imported_clicks_column",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec(`
+					ALTER TABLE urls
+					ADD COLUMN IF NOT EXISTS imported_clicks INTEGER NOT NULL DEFAULT 0
+				`).Error; err != nil {
+					return err
+				}
+				// Clicks without visit rows behind them were carried over by an import
+				return tx.Exec(`
+					UPDATE urls u
+					SET imported_clicks = GREATEST(u.click_count
+						- (SELECT COUNT(*) FROM url_visits WHERE url_id = u.id AND NOT is_bot)
+						- (SELECT COALESCE(SUM(clicks), 0) FROM url_daily_stats WHERE url_id = u.id), 0)
+					WHERE u.click_count > 0
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					ALTER TABLE urls
+					DROP COLUMN IF EXISTS imported_clicks
+				`).Error
+			},
+		},
 	}
 }
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"log"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/util"
+)
+
+// minVisitRetention keeps at least one full day of raw visits so rollups only ever see closed days
+const minVisitRetention = 24 * time.Hour
+
+// VisitRetention is how long raw url_visits rows are kept before being rolled into
+// daily aggregates; zero keeps raw visits forever and disables the rollup job
+var VisitRetention time.Duration
+
+// VisitRollupInterval is how often the rollup job looks for visits past the retention age
+var VisitRollupInterval = time.Hour
+
+// InitVisitRetention reads the visit retention settings from the environment
+func InitVisitRetention() {
+	VisitRetention = durationFromEnv("VISIT_RETENTION", VisitRetention)
+	VisitRollupInterval = durationFromEnv("VISIT_ROLLUP_INTERVAL", VisitRollupInterval)
+
+	if VisitRetention > 0 && VisitRetention < minVisitRetention {
+		log.Printf("VISIT_RETENTION below %s, using %s", util.FormatTTL(minVisitRetention), util.FormatTTL(minVisitRetention))
+		VisitRetention = minVisitRetention
+	}
+}
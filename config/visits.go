@@ -0,0 +1,59 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxVisitBatchSize keeps one batched visit INSERT well under PostgreSQL's bind parameter limit
+const maxVisitBatchSize = 2000
+
+// VisitRecording selects how redirects record visits: "async" queues them for batched writes
+// by background workers, "sync" writes each visit before the redirect is sent
+var VisitRecording = "async"
+
+// Asynchronous visit recorder sizing
+var (
+	VisitQueueSize     = 10000       // Visits waiting to be written; redirects record synchronously when it is full
+	VisitWorkers       = 4           // Goroutines writing batches
+	VisitBatchSize     = 500         // Largest batch written in one transaction
+	VisitFlushInterval = time.Second // Longest a queued visit waits before its batch is written
+)
+
+// VisitMetricsToken is the bearer token GET /api/metrics/visits requires; the endpoint is disabled when empty
+var VisitMetricsToken string
+
+// InitVisitRecording reads the visit recording settings from the environment
+func InitVisitRecording() {
+	if value := strings.ToLower(strings.TrimSpace(os.Getenv("VISIT_RECORDING"))); value != "" {
+		if value == "async" || value == "sync" {
+			VisitRecording = value
+		} else {
+			log.Printf("Invalid VISIT_RECORDING %q, keeping %s", value, VisitRecording)
+		}
+	}
+
+	VisitQueueSize = intFromEnv("VISIT_QUEUE_SIZE", VisitQueueSize, 1, 1<<20)
+	VisitWorkers = intFromEnv("VISIT_WORKERS", VisitWorkers, 1, 64)
+	VisitBatchSize = intFromEnv("VISIT_BATCH_SIZE", VisitBatchSize, 1, maxVisitBatchSize)
+	VisitFlushInterval = durationFromEnv("VISIT_FLUSH_INTERVAL", VisitFlushInterval)
+	VisitMetricsToken = strings.TrimSpace(os.Getenv("METRICS_TOKEN"))
+}
+
+// intFromEnv parses an integer env var within [min, max], falling back to the default when unset or invalid
+func intFromEnv(key string, defaultValue, min, max int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || parsed < min || parsed > max {
+		log.Printf("Invalid %s %q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
@@ -0,0 +1,288 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/google/uuid"
+)
+
+// maxTimeSeriesBuckets caps the number of buckets a single time series request may produce
+const maxTimeSeriesBuckets = 2000
+
+// topBreakdownLimit is the number of entries returned for each stats breakdown
+const topBreakdownLimit = 10
+
+// dailyUniquesExpression counts each human visitor once per UTC day, the way rolled-up days
+// store unique_visitors, so raw and rolled-up data can be summed consistently
+const dailyUniquesExpression = "COUNT(DISTINCT to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') || NULLIF(ip_address, ''))"
+
+// breakdownColumns lists the url_visits columns that may be grouped for a breakdown
+var breakdownColumns = map[string]bool{
+	"referrer_domain": true,
+	"browser":         true,
+	"os":              true,
+	"device_type":     true,
+	"country":         true,
+	"city":            true,
+}
+
+// BreakdownEntry is one value of a per-link visit breakdown with its visit count
+type BreakdownEntry struct {
+	Value  string
+	Visits int64
+}
+
+// TimeSeriesQuery describes the range and granularity of a click time series
+type TimeSeriesQuery struct {
+	Interval string         // hour, day, week or month
+	From     time.Time      // Inclusive start of the range
+	To       time.Time      // Exclusive end of the range
+	Location *time.Location // Timezone used to align bucket boundaries
+}
+
+// TimeBucket holds the visit totals for one interval of a time series
+// A rolled-up bucket includes days whose raw visits were aggregated: its unique visitors are
+// summed daily uniques, and an hour bucket has no data at all since only daily totals remain
+type TimeBucket struct {
+	Start          time.Time
+	Clicks         int64
+	UniqueVisitors int64
+	RolledUp       bool
+}
+
+// URLTimeSeries represents clicks over time for a URL
+type URLTimeSeries struct {
+	ShortCode      string
+	Interval       string
+	Timezone       string
+	From           time.Time
+	To             time.Time
+	TotalClicks    int64
+	UniqueVisitors int64 // Distinct visitors among the range's raw visits
+	DailyUniques   int64 // Sum of each UTC day's distinct visitors, covering rolled-up days too
+	Buckets        []TimeBucket
+}
+
+// Validate checks the interval and range of a time series query
+func (q TimeSeriesQuery) Validate() error {
+	switch q.Interval {
+	case "hour", "day", "week", "month":
+	default:
+		return fmt.Errorf("interval must be one of hour, day, week or month")
+	}
+	if !q.From.Before(q.To) {
+		return fmt.Errorf("from must be before to")
+	}
+	if q.Location == nil {
+		q.Location = time.UTC
+	}
+	if len(bucketStarts(q)) > maxTimeSeriesBuckets {
+		return fmt.Errorf("range produces too many buckets (max %d), use a coarser interval", maxTimeSeriesBuckets)
+	}
+	return nil
+}
+
+// GetURLTimeSeries returns clicks and unique visitors grouped by interval if the URL belongs to the specified user
+// Buckets with no visits are included with zero counts so charts can be drawn directly
+func (c *URLController) GetURLTimeSeries(code string, userID uuid.UUID, query TimeSeriesQuery) (*URLTimeSeries, error) {
+	if query.Location == nil {
+		query.Location = time.UTC
+	}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	starts := bucketStarts(query)
+
+	urlRecord, err := c.getOwnedURL(code, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bucket in the caller's timezone: convert to local wall time, truncate, and group
+	var rows []struct {
+		Bucket         time.Time
+		Clicks         int64
+		UniqueVisitors int64
+	}
+	if err := c.DB.Model(&models.URLVisit{}).
+		Select("date_trunc(?, created_at AT TIME ZONE ?) AS bucket, COUNT(*) AS clicks, COUNT(DISTINCT NULLIF(ip_address, '')) AS unique_visitors", query.Interval, query.Location.String()).
+		Where("url_id = ? AND NOT is_bot AND created_at >= ? AND created_at < ?", urlRecord.ID, query.From, query.To).
+		Group("bucket").
+		Order("bucket").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	// The database returns local wall-clock times; re-anchor them in the requested location
+	counts := make(map[int64]TimeBucket, len(rows))
+	for _, row := range rows {
+		b := row.Bucket
+		start := time.Date(b.Year(), b.Month(), b.Day(), b.Hour(), 0, 0, 0, query.Location)
+		bucket := counts[start.Unix()]
+		bucket.Start = start
+		bucket.Clicks += row.Clicks
+		bucket.UniqueVisitors += row.UniqueVisitors
+		counts[start.Unix()] = bucket
+	}
+
+	// Rolled-up UTC days overlapping the range, including a partial first or last day
+	var rolledUp []struct {
+		Day            time.Time
+		Clicks         int64
+		UniqueVisitors int64
+	}
+	if err := c.DB.Model(&models.URLDailyStat{}).
+		Select("day::timestamp AS day, clicks, unique_visitors").
+		Where("url_id = ? AND day >= ?::date AND day <= ?::date", urlRecord.ID,
+			query.From.UTC().Format("2006-01-02"), query.To.Add(-time.Nanosecond).UTC().Format("2006-01-02")).
+		Scan(&rolledUp).Error; err != nil {
+		return nil, err
+	}
+
+	var rolledUpClicks, rolledUpUniques int64
+	rolledUpDays := make(map[string]bool, len(rolledUp))
+	for _, row := range rolledUp {
+		rolledUpClicks += row.Clicks
+		rolledUpUniques += row.UniqueVisitors
+		day := time.Date(row.Day.Year(), row.Day.Month(), row.Day.Day(), 0, 0, 0, 0, time.UTC)
+		rolledUpDays[day.Format("2006-01-02")] = true
+		if query.Interval == "hour" {
+			continue // Marked on the buckets below
+		}
+
+		// A UTC day spans two local dates outside UTC; count it where its midday falls,
+		// kept inside the range when only part of the day overlaps it
+		at := day.Add(12 * time.Hour)
+		if at.Before(query.From) {
+			at = query.From
+		}
+		if !at.Before(query.To) {
+			at = query.To.Add(-time.Nanosecond)
+		}
+		start := truncateToInterval(at.In(query.Location), query.Interval)
+		bucket := counts[start.Unix()]
+		bucket.Start = start
+		bucket.Clicks += row.Clicks
+		bucket.UniqueVisitors += row.UniqueVisitors
+		bucket.RolledUp = true
+		counts[start.Unix()] = bucket
+	}
+
+	buckets := make([]TimeBucket, 0, len(starts))
+	var totalClicks int64
+	for _, start := range starts {
+		bucket, ok := counts[start.Unix()]
+		if !ok {
+			bucket = TimeBucket{Start: start}
+		}
+		if query.Interval == "hour" {
+			// Hours of a rolled-up day have no data rather than zero visits
+			last := nextInterval(start, query.Interval).Add(-time.Nanosecond)
+			bucket.RolledUp = rolledUpDays[start.UTC().Format("2006-01-02")] || rolledUpDays[last.UTC().Format("2006-01-02")]
+		}
+		totalClicks += bucket.Clicks
+		buckets = append(buckets, bucket)
+	}
+	if query.Interval == "hour" {
+		totalClicks += rolledUpClicks
+	}
+
+	// Unique visitors across the whole range cannot be derived by summing buckets
+	var uniques struct {
+		UniqueVisitors int64
+		DailyUniques   int64
+	}
+	if err := c.DB.Model(&models.URLVisit{}).
+		Where("url_id = ? AND NOT is_bot AND created_at >= ? AND created_at < ?", urlRecord.ID, query.From, query.To).
+		Select("COUNT(DISTINCT NULLIF(ip_address, '')) AS unique_visitors, " + dailyUniquesExpression + " AS daily_uniques").
+		Scan(&uniques).Error; err != nil {
+		return nil, err
+	}
+
+	return &URLTimeSeries{
+		ShortCode:      urlRecord.ShortCode,
+		Interval:       query.Interval,
+		Timezone:       query.Location.String(),
+		From:           query.From,
+		To:             query.To,
+		TotalClicks:    totalClicks,
+		UniqueVisitors: uniques.UniqueVisitors,
+		DailyUniques:   uniques.DailyUniques + rolledUpUniques,
+		Buckets:        buckets,
+	}, nil
+}
+
+// truncateToInterval aligns t to the start of its bucket, matching PostgreSQL date_trunc
+func truncateToInterval(t time.Time, interval string) time.Time {
+	switch interval {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case "week":
+		// date_trunc('week') starts weeks on Monday
+		offset := (int(t.Weekday()) + 6) % 7
+		day := t.AddDate(0, 0, -offset)
+		return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, t.Location())
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+// nextInterval returns the start of the bucket following start
+func nextInterval(start time.Time, interval string) time.Time {
+	switch interval {
+	case "hour":
+		return time.Date(start.Year(), start.Month(), start.Day(), start.Hour()+1, 0, 0, 0, start.Location())
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// bucketStarts lists every bucket start covering the query range in the query's timezone
+func bucketStarts(query TimeSeriesQuery) []time.Time {
+	starts := make([]time.Time, 0)
+	end := query.To.In(query.Location)
+	for start := truncateToInterval(query.From.In(query.Location), query.Interval); start.Before(end); start = nextInterval(start, query.Interval) {
+		starts = append(starts, start)
+		if len(starts) > maxTimeSeriesBuckets {
+			break
+		}
+	}
+	return starts
+}
+
+// GetVisitBreakdown returns the most common values of a url_visits column among a URL's human visits
+// Empty values are reported under emptyLabel; rolled-up days contribute their stored top values
+func (c *URLController) GetVisitBreakdown(urlID uint, column, emptyLabel string, limit int) ([]BreakdownEntry, error) {
+	if !breakdownColumns[column] {
+		return nil, fmt.Errorf("unsupported breakdown column %q", column)
+	}
+
+	entries := make([]BreakdownEntry, 0)
+	if err := c.DB.Raw(fmt.Sprintf(`
+		SELECT COALESCE(NULLIF(value, ''), ?) AS value, SUM(visits)::bigint AS visits
+		FROM (
+			SELECT %s AS value, COUNT(*) AS visits
+			FROM url_visits
+			WHERE url_id = ? AND NOT is_bot
+			GROUP BY 1
+			UNION ALL
+			SELECT value, visits
+			FROM url_daily_breakdowns
+			WHERE url_id = ? AND dimension = ?
+		) combined
+		GROUP BY 1
+		ORDER BY visits DESC, value
+		LIMIT ?
+	`, column), emptyLabel, urlID, urlID, column, limit).Scan(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/Debsnil24/URL_Shortner.git/service"
+	"github.com/Debsnil24/URL_Shortner.git/util"
+)
+
+// screenDestination checks a destination against the blocklist before a link is saved
+// In reject mode a match is returned as a URL validation error; in quarantine mode the
+// match is returned as the reason to store on the link, which then never redirects
+func (c *URLController) screenDestination(originalURL string) (string, error) {
+	match, blocked := c.Blocklist.Check(originalURL)
+	if !blocked {
+		return "", nil
+	}
+	if c.Blocklist.Action() == service.BlocklistActionQuarantine {
+		return match.String(), nil
+	}
+	return "", &util.URLValidationError{Code: util.URLErrorBlocklisted, Message: "url destination is blocklisted as unsafe"}
+}
+
+// IsBlocked re-checks a link's destination against the current blocklist at redirect time
+// The stored blocked_reason is updated whenever the verdict changes, so links whose destination
+// was listed after creation stop working and links cleared from the list work again. A link is
+// only cleared when the source that blocked it loaded successfully; while its file is missing or
+// broken the stored verdict stands
+func (c *URLController) IsBlocked(urlRecord *models.URL) (bool, error) {
+	reason := ""
+	if match, blocked := c.Blocklist.Check(urlRecord.OriginalURL); blocked {
+		reason = match.String()
+	}
+	if reason == "" && urlRecord.BlockedReason != "" && !c.Blocklist.Verifies(urlRecord.BlockedReason) {
+		return true, nil
+	}
+	if reason == urlRecord.BlockedReason {
+		return reason != "", nil
+	}
+
+	var blockedAt *time.Time
+	if reason != "" {
+		now := time.Now()
+		blockedAt = &now
+	}
+	if err := c.DB.Model(&models.URL{}).Where("id = ?", urlRecord.ID).UpdateColumns(map[string]interface{}{
+		"blocked_reason": reason,
+		"blocked_at":     blockedAt,
+	}).Error; err != nil {
+		return reason != "", err
+	}
+	urlRecord.BlockedReason = reason
+	urlRecord.BlockedAt = blockedAt
+	c.InvalidateCodes(urlRecord.ShortCode)
+
+	return reason != "", nil
+}
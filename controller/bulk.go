@@ -0,0 +1,540 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/Debsnil24/URL_Shortner.git/util"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxBulkShortenItems caps the number of links a single bulk request may create
+const MaxBulkShortenItems = 500
+
+// MaxBulkPasswordItems caps the password-protected links of a bulk request, since each
+// password costs a deliberately slow bcrypt hash
+const MaxBulkPasswordItems = 50
+
+// MaxImportRows caps the number of rows a single CSV import may contain
+const MaxImportRows = 10000
+
+// bulkChunkSize is the number of links checked and inserted per transaction
+const bulkChunkSize = 100
+
+// BulkShortenItem is one link of a bulk creation request
+type BulkShortenItem struct {
+	OriginalURL  string
+	Options      ShortenOptions
+	TagIDs       []uint
+	TagNames     []string   // Tags looked up by name and created when the user has none with that name
+	FallbackCode bool       // Use a random code instead of failing when Options.Alias is unusable or taken
+	CreatedAt    *time.Time // Creation date carried over from another shortener
+	ClickCount   int        // Clicks carried over from another shortener
+
+	newTagNames []string // TagNames the user has no tag for yet; created with the link
+}
+
+// BulkShortenResult reports the outcome of one bulk item, in request order
+type BulkShortenResult struct {
+	URL  *models.URL // Created link; nil when Err is set
+	Tags []TagRef
+	Err  error
+}
+
+// GenerateShortCodes creates many links for a user at once
+// Each chunk checks alias and random code uniqueness, folder and tag ownership with one query
+// apiece and inserts its links in a single transaction; items that fail validation are
+// reported individually without affecting the rest. A chunk that fails as a whole reports the
+// error on its own items, so links already committed by earlier chunks are still returned
+func (c *URLController) GenerateShortCodes(userID uuid.UUID, items []BulkShortenItem) ([]BulkShortenResult, error) {
+	results := make([]BulkShortenResult, len(items))
+
+	items, err := c.resolveTagNames(userID, items, results)
+	if err != nil {
+		return nil, err
+	}
+
+	ownedTags, ownedFolders, err := c.ownedLabels(userID, items)
+	if err != nil {
+		return nil, err
+	}
+
+	// Items without a redirect status get the user's default
+	defaultStatus, err := c.defaultRedirectStatus(userID)
+	if err != nil {
+		return nil, err
+	}
+	withDefaults := make([]BulkShortenItem, len(items))
+	for i, item := range items {
+		if item.Options.RedirectStatus == 0 {
+			item.Options.RedirectStatus = defaultStatus
+		}
+		withDefaults[i] = item
+	}
+	items = withDefaults
+	hashBulkPasswords(items, results)
+
+	seenAliases := make(map[string]bool)
+	for start := 0; start < len(items); start += bulkChunkSize {
+		end := min(start+bulkChunkSize, len(items))
+		if err := c.generateChunk(userID, items[start:end], results[start:end], ownedTags, ownedFolders, seenAliases); err != nil {
+			for i := start; i < end; i++ {
+				if results[i].URL == nil && results[i].Err == nil {
+					results[i].Err = err
+				}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// hashBulkPasswords hashes the items' passwords in parallel, one bcrypt per CPU at a time
+// Items are updated in place; a failed hash is reported as the item's error
+func hashBulkPasswords(items []BulkShortenItem, results []BulkShortenResult) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for i := range items {
+		if items[i].Options.Password == "" || results[i].Err != nil {
+			continue
+		}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			opts, err := withPasswordHash(items[i].Options)
+			if err != nil {
+				results[i].Err = err
+				return
+			}
+			items[i].Options = opts
+		}(i)
+	}
+	wg.Wait()
+}
+
+// resolveTagNames adds the IDs of each item's existing TagNames to its TagIDs and leaves the
+// names without a tag in newTagNames, to be created in the transaction that inserts the link so
+// items that fail never leave tags behind
+// Items with an invalid tag name get an error result; the returned items are copies
+func (c *URLController) resolveTagNames(userID uuid.UUID, items []BulkShortenItem, results []BulkShortenResult) ([]BulkShortenItem, error) {
+	normalized := make([][]string, len(items))
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+	for i, item := range items {
+		for _, name := range item.TagNames {
+			tagName, err := normalizeLabelName(name, MaxTagNameLength)
+			if err != nil {
+				results[i].Err = fmt.Errorf("tag %s", err)
+				break
+			}
+			normalized[i] = append(normalized[i], tagName)
+			if key := strings.ToLower(tagName); !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return items, nil
+	}
+
+	var existing []models.Tag
+	if err := c.DB.Where("user_id = ? AND lower(name) IN ?", userID, keys).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	ids := make(map[string]uint, len(existing))
+	for _, tag := range existing {
+		ids[strings.ToLower(tag.Name)] = tag.ID
+	}
+
+	resolved := make([]BulkShortenItem, len(items))
+	for i, item := range items {
+		resolved[i] = item
+		if results[i].Err != nil || len(normalized[i]) == 0 {
+			continue
+		}
+		tagIDs := append([]uint{}, item.TagIDs...)
+		for _, name := range normalized[i] {
+			if id, ok := ids[strings.ToLower(name)]; ok {
+				tagIDs = append(tagIDs, id)
+			} else {
+				resolved[i].newTagNames = append(resolved[i].newTagNames, name)
+			}
+		}
+		resolved[i].TagIDs = tagIDs
+	}
+	return resolved, nil
+}
+
+// linkTags creates the items' missing tags and links each new record to its item's tags
+// records[n] was created from items[n]; everything runs in tx so it commits with the links
+func linkTags(tx *gorm.DB, userID uuid.UUID, records []models.URL, items []BulkShortenItem) error {
+	names := make(map[string]string) // Lowercased name to the spelling used when creating it
+	for _, item := range items {
+		for _, name := range item.newTagNames {
+			if _, ok := names[strings.ToLower(name)]; !ok {
+				names[strings.ToLower(name)] = name
+			}
+		}
+	}
+
+	created := make(map[string]uint, len(names))
+	if len(names) > 0 {
+		tags := make([]models.Tag, 0, len(names))
+		keys := make([]string, 0, len(names))
+		for key, name := range names {
+			tags = append(tags, models.Tag{UserID: userID, Name: name})
+			keys = append(keys, key)
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+			return err
+		}
+		var existing []models.Tag
+		if err := tx.Where("user_id = ? AND lower(name) IN ?", userID, keys).Find(&existing).Error; err != nil {
+			return err
+		}
+		for _, tag := range existing {
+			created[strings.ToLower(tag.Name)] = tag.ID
+		}
+	}
+
+	links := make([]models.URLTag, 0)
+	for n, item := range items {
+		tagIDs := append([]uint{}, item.TagIDs...)
+		for _, name := range item.newTagNames {
+			tagIDs = append(tagIDs, created[strings.ToLower(name)])
+		}
+		for _, tagID := range uniqueIDs(tagIDs) {
+			links = append(links, models.URLTag{URLID: records[n].ID, TagID: tagID})
+		}
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	return tx.Create(&links).Error
+}
+
+// ownedLabels returns the tag and folder IDs referenced by the items that belong to the user
+func (c *URLController) ownedLabels(userID uuid.UUID, items []BulkShortenItem) (map[uint]bool, map[uint]bool, error) {
+	tagIDs := make([]uint, 0)
+	folderIDs := make([]uint, 0)
+	for _, item := range items {
+		tagIDs = append(tagIDs, item.TagIDs...)
+		if item.Options.FolderID != nil {
+			folderIDs = append(folderIDs, *item.Options.FolderID)
+		}
+	}
+
+	ownedTags := make(map[uint]bool)
+	if len(tagIDs) > 0 {
+		var ids []uint
+		if err := c.DB.Model(&models.Tag{}).Where("id IN ? AND user_id = ?", tagIDs, userID).Pluck("id", &ids).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, id := range ids {
+			ownedTags[id] = true
+		}
+	}
+
+	ownedFolders := make(map[uint]bool)
+	if len(folderIDs) > 0 {
+		var ids []uint
+		if err := c.DB.Model(&models.Folder{}).Where("id IN ? AND user_id = ?", folderIDs, userID).Pluck("id", &ids).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, id := range ids {
+			ownedFolders[id] = true
+		}
+	}
+
+	return ownedTags, ownedFolders, nil
+}
+
+// generateChunk validates, reserves codes for and inserts one chunk of bulk items
+func (c *URLController) generateChunk(userID uuid.UUID, items []BulkShortenItem, results []BulkShortenResult, ownedTags, ownedFolders map[uint]bool, seenAliases map[string]bool) error {
+	pending := make([]int, 0, len(items)) // Indexes of items still valid
+	random := make(map[int]bool)          // Items that get a generated code
+	reasons := make(map[int]string)       // Blocklist matches of quarantined items
+	aliases := make([]string, 0)
+	for i, item := range items {
+		if results[i].Err != nil {
+			continue
+		}
+		if item.Options.Alias == "" {
+			random[i] = true
+		} else {
+			err := util.ValidateAlias(item.Options.Alias)
+			if err == nil && seenAliases[item.Options.Alias] {
+				err = errors.New("alias already in use")
+			}
+			switch {
+			case err == nil:
+				seenAliases[item.Options.Alias] = true
+				aliases = append(aliases, item.Options.Alias)
+			case item.FallbackCode:
+				random[i] = true
+			default:
+				results[i].Err = err
+				continue
+			}
+		}
+		if item.Options.FolderID != nil && !ownedFolders[*item.Options.FolderID] {
+			results[i].Err = errors.New("folder not found")
+			continue
+		}
+		if !allOwned(item.TagIDs, ownedTags) {
+			results[i].Err = errors.New("tag not found")
+			continue
+		}
+		blockedReason, err := c.screenDestination(item.OriginalURL)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		reasons[i] = blockedReason
+		pending = append(pending, i)
+	}
+
+	taken, err := c.existingCodes(aliases)
+	if err != nil {
+		return err
+	}
+
+	records := make([]models.URL, 0, len(pending))
+	recordItems := make([]int, 0, len(pending))
+	recordSources := make([]BulkShortenItem, 0, len(pending))
+	codes := make(map[int]string, len(pending))
+	for _, i := range pending {
+		if random[i] {
+			continue
+		}
+		alias := items[i].Options.Alias
+		if !taken[alias] {
+			codes[i] = alias
+		} else if items[i].FallbackCode {
+			random[i] = true
+		} else {
+			results[i].Err = errors.New("alias already in use")
+		}
+	}
+	if err := c.assignRandomCodes(pending, random, codes, seenAliases); err != nil {
+		return err
+	}
+	for _, i := range pending {
+		if _, ok := codes[i]; !ok && random[i] && results[i].Err == nil {
+			// All attempts exhausted; give up on this item rather than the chunk
+			results[i].Err = errors.New("failed to generate unique short code after maximum attempts")
+		}
+	}
+
+	for _, i := range pending {
+		code, ok := codes[i]
+		if !ok || results[i].Err != nil {
+			continue
+		}
+		opts := items[i].Options
+		opts.blockedReason = reasons[i]
+		record, err := newURLRecord(code, items[i].OriginalURL, userID, opts)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		if items[i].CreatedAt != nil {
+			record.CreatedAt = *items[i].CreatedAt
+		}
+		record.ClickCount = items[i].ClickCount
+		record.ImportedClicks = items[i].ClickCount
+		records = append(records, record)
+		recordItems = append(recordItems, i)
+		recordSources = append(recordSources, items[i])
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	err = c.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&records).Error; err != nil {
+			return err
+		}
+		return linkTags(tx, userID, records, recordSources)
+	})
+	if err != nil {
+		if !isUniqueViolation(err) {
+			return err
+		}
+		// A concurrent request took one of the codes; create the chunk's links one by one instead
+		for n := range records {
+			records[n].ID = 0
+		}
+		return c.generateIndividually(userID, items, records, recordItems, results)
+	}
+
+	codesCreated := make([]string, len(records))
+	for n := range records {
+		codesCreated[n] = records[n].ShortCode
+	}
+	c.InvalidateCodes(codesCreated...) // Drop any negative entries from before the codes existed
+	return c.reportCreated(records, recordItems, results)
+}
+
+// reportCreated sets the results of committed records and loads their tags
+// The links are reported even when loading the tags fails, since they already exist
+func (c *URLController) reportCreated(records []models.URL, recordItems []int, results []BulkShortenResult) error {
+	for n, i := range recordItems {
+		record := records[n]
+		results[i].URL = &record
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	tags, err := c.getURLTags(recordIDs(records))
+	if err != nil {
+		return err
+	}
+	for _, i := range recordItems {
+		results[i].Tags = tags[results[i].URL.ID]
+	}
+	return nil
+}
+
+// existingCodes returns which of the given short codes are already taken
+func (c *URLController) existingCodes(codes []string) (map[string]bool, error) {
+	taken := make(map[string]bool)
+	if len(codes) == 0 {
+		return taken, nil
+	}
+
+	var existing []string
+	if err := c.DB.Model(&models.URL{}).Where("short_code IN ?", codes).Pluck("short_code", &existing).Error; err != nil {
+		return nil, err
+	}
+	for _, code := range existing {
+		taken[code] = true
+	}
+	return taken, nil
+}
+
+// assignRandomCodes generates codes for the pending items marked random, checking each round of candidates in one query
+// Items still without a code after the last attempt are left out of codes
+func (c *URLController) assignRandomCodes(pending []int, random map[int]bool, codes map[int]string, reserved map[string]bool) error {
+	const maxAttempts = 10 // Maximum attempts to generate a unique short code
+
+	waiting := make([]int, 0)
+	for _, i := range pending {
+		if random[i] {
+			waiting = append(waiting, i)
+		}
+	}
+
+	for attempt := 0; attempt < maxAttempts && len(waiting) > 0; attempt++ {
+		candidates := make(map[int]string, len(waiting))
+		list := make([]string, 0, len(waiting))
+		for _, i := range waiting {
+			code := util.GenerateShortCode()
+			candidates[i] = code
+			list = append(list, code)
+		}
+
+		taken, err := c.existingCodes(list)
+		if err != nil {
+			return err
+		}
+
+		retry := make([]int, 0)
+		for _, i := range waiting {
+			code := candidates[i]
+			if taken[code] || reserved[code] {
+				retry = append(retry, i)
+				continue
+			}
+			reserved[code] = true
+			codes[i] = code
+		}
+		waiting = retry
+	}
+	return nil
+}
+
+// generateIndividually inserts prepared records one transaction at a time, each with its tags
+// A record whose code was taken meanwhile gets a new random code, or fails with "alias already
+// in use" when it asked for that alias without FallbackCode; errors are reported per item
+func (c *URLController) generateIndividually(userID uuid.UUID, items []BulkShortenItem, records []models.URL, recordItems []int, results []BulkShortenResult) error {
+	const maxAttempts = 10 // Maximum attempts to generate a unique short code
+
+	created := make([]models.URL, 0, len(records))
+	createdItems := make([]int, 0, len(records))
+	for n, i := range recordItems {
+		record := records[n]
+		var err error
+		for attempt := 0; attempt < maxAttempts; attempt++ {
+			record.ID = 0
+			err = c.DB.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Create(&record).Error; err != nil {
+					return err
+				}
+				return linkTags(tx, userID, []models.URL{record}, []BulkShortenItem{items[i]})
+			})
+			if err == nil || !isUniqueViolation(err) {
+				break
+			}
+			if record.ShortCode == items[i].Options.Alias && !items[i].FallbackCode {
+				err = errors.New("alias already in use")
+				break
+			}
+			record.ShortCode = util.GenerateShortCode()
+		}
+		if err != nil {
+			if isUniqueViolation(err) {
+				err = errors.New("failed to generate unique short code after maximum attempts")
+			}
+			results[i].Err = err
+			continue
+		}
+		c.InvalidateCodes(record.ShortCode)
+		created = append(created, record)
+		createdItems = append(createdItems, i)
+	}
+	return c.reportCreated(created, createdItems, results)
+}
+
+// allOwned reports whether every ID is in the owned set
+func allOwned(ids []uint, owned map[uint]bool) bool {
+	for _, id := range ids {
+		if !owned[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// uniqueIDs drops repeated IDs, keeping the first occurrence order
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// recordIDs lists the IDs of the given URL rows
+func recordIDs(records []models.URL) []uint {
+	ids := make([]uint, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// cachedURL holds the fields of a URL the redirect path needs
+type cachedURL struct {
+	ID             uint       `json:"id"`
+	ShortCode      string     `json:"code"`
+	OriginalURL    string     `json:"url"`
+	UserID         uuid.UUID  `json:"user_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	ClickCount     int        `json:"clicks"`
+	MaxClicks      *int       `json:"max_clicks,omitempty"`
+	PasswordHash   string     `json:"password_hash,omitempty"`
+	BlockedReason  string     `json:"blocked_reason,omitempty"`
+	RedirectStatus int        `json:"redirect_status,omitempty"`
+}
+
+// ResolveShortCode looks up a short code for a redirect, serving hot and unknown codes from the cache
+// The cached click count may lag behind; RecordVisitAndIncrement enforces max_clicks atomically
+// Returns gorm.ErrRecordNotFound for unknown codes like GetURLByCode
+func (c *URLController) ResolveShortCode(code string) (*models.URL, error) {
+	if data, hit := c.Cache.Get(code); hit {
+		if len(data) == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		var cached cachedURL
+		if err := json.Unmarshal(data, &cached); err == nil {
+			return &models.URL{
+				ID:             cached.ID,
+				ShortCode:      cached.ShortCode,
+				OriginalURL:    cached.OriginalURL,
+				UserID:         cached.UserID,
+				CreatedAt:      cached.CreatedAt,
+				ExpiresAt:      cached.ExpiresAt,
+				ClickCount:     cached.ClickCount,
+				MaxClicks:      cached.MaxClicks,
+				PasswordHash:   cached.PasswordHash,
+				BlockedReason:  cached.BlockedReason,
+				RedirectStatus: cached.RedirectStatus,
+			}, nil
+		}
+		log.Printf("event=url_cache_error op=decode code=%s", code)
+	}
+
+	generation := c.Cache.Generation()
+	urlRecord, err := c.GetURLByCode(code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Cache.SetMissing(code, generation)
+			// A link created between the lookup and SetMissing, possibly on another instance,
+			// was invalidated before the negative entry was written; re-check so it is not
+			// hidden for the negative TTL
+			if exists, checkErr := c.shortCodeExists(code); checkErr != nil || exists {
+				c.Cache.Invalidate(code)
+			}
+		}
+		return nil, err
+	}
+
+	data, err := json.Marshal(cachedURL{
+		ID:             urlRecord.ID,
+		ShortCode:      urlRecord.ShortCode,
+		OriginalURL:    urlRecord.OriginalURL,
+		UserID:         urlRecord.UserID,
+		CreatedAt:      urlRecord.CreatedAt,
+		ExpiresAt:      urlRecord.ExpiresAt,
+		ClickCount:     urlRecord.ClickCount,
+		MaxClicks:      urlRecord.MaxClicks,
+		PasswordHash:   urlRecord.PasswordHash,
+		BlockedReason:  urlRecord.BlockedReason,
+		RedirectStatus: urlRecord.RedirectStatus,
+	})
+	if err == nil {
+		c.Cache.Set(code, data, generation)
+	}
+	return urlRecord, nil
+}
+
+// shortCodeExists reports whether any link uses the short code
+func (c *URLController) shortCodeExists(code string) (bool, error) {
+	var count int64
+	if err := c.DB.Model(&models.URL{}).Where("short_code = ?", code).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// InvalidateCodes drops short codes from the redirect cache after their links were created, changed or removed
+func (c *URLController) InvalidateCodes(codes ...string) {
+	c.Cache.Invalidate(codes...)
+}
@@ -2,11 +2,16 @@ package controller
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/Debsnil24/URL_Shortner.git/service"
 	"github.com/Debsnil24/URL_Shortner.git/util"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -14,12 +19,21 @@ import (
 type URLSummary struct {
 	ShortCode          string
 	OriginalURL        string
+	Title              string
+	FolderID           *uint
+	Tags               []TagRef
 	ClickCount         int
 	CreatedAt          time.Time
 	UpdatedAt          time.Time
 	ExpiresAt          *time.Time
+	MaxClicks          *int
+	PasswordProtected  bool
+	RedirectStatus     int
+	BlockedReason      string // Non-empty when the destination matched the blocklist
 	TotalVisits        int64
-	UniqueVisitors     int64
+	BotVisits          int64
+	UniqueVisitors     int64 // Distinct visitors among raw visits
+	DailyUniques       int64 // Sum of each UTC day's distinct visitors, covering rolled-up days too
 	LastVisitAt        *time.Time
 	LastVisitUserAgent *string
 }
@@ -30,23 +44,86 @@ type URLStats struct {
 	OriginalURL        string
 	ClickCount         int
 	TotalVisits        int64
-	UniqueVisitors     int64
+	UniqueVisitors     int64 // Distinct visitors among raw visits
+	DailyUniques       int64 // Sum of each UTC day's distinct visitors, covering rolled-up days too
 	LastVisitAt        *time.Time
 	LastVisitUserAgent string
+	TopReferrers       []BreakdownEntry
+	Browsers           []BreakdownEntry
+	OperatingSystems   []BreakdownEntry
+	DeviceTypes        []BreakdownEntry
+	Countries          []BreakdownEntry
+	Cities             []BreakdownEntry
+	BotVisits          int64
 }
 
 type URLController struct {
-	DB *gorm.DB
+	DB        *gorm.DB
+	Bots      *service.BotDetector
+	Geo       *service.GeoLocator
+	IPs       *service.IPAnonymizer
+	Blocklist *service.URLBlocklist
+	Cache     *service.URLCache
 }
 
 // NewURLController creates a new URL controller instance
 func NewURLController(db *gorm.DB) *URLController {
 	return &URLController{
-		DB: db,
+		DB:        db,
+		Bots:      service.GetBotDetector(),  // Use singleton bot detector
+		Geo:       service.GetGeoLocator(),   // Use singleton GeoIP database
+		IPs:       service.GetIPAnonymizer(), // Use singleton IP privacy policy
+		Blocklist: service.GetURLBlocklist(), // Use singleton destination blocklist
+		Cache:     service.GetURLCache(),     // Use singleton redirect cache
 	}
 }
 
-func (c *URLController) GenerateShortCode(originalURL string, userID uuid.UUID) (*models.URL, error) {
+// ShortenOptions carries optional caller-supplied settings for a new short link
+type ShortenOptions struct {
+	Alias     string     // Custom short code; a random code is generated when empty
+	ExpiresAt *time.Time // Resolved expiry; nil means the link never expires
+	MaxClicks *int       // Optional cap on successful redirects
+	Password  string     // Plain-text password to protect the link with; hashed before storage
+	Title     string     // Optional label for the owner's dashboard
+	FolderID  *uint      // Folder to file the link under; must belong to the same user
+	// RedirectStatus is 301, 302, 307 or 308; 0 uses the owner's default_redirect_status
+	RedirectStatus int
+
+	blockedReason string // Set by screenDestination when the link is quarantined
+	passwordHash  string // Set by withPasswordHash so retries do not hash the password again
+}
+
+func (c *URLController) GenerateShortCode(originalURL string, userID uuid.UUID, opts ShortenOptions) (*models.URL, error) {
+	if opts.FolderID != nil {
+		owned, err := c.ownsFolder(userID, *opts.FolderID)
+		if err != nil {
+			return nil, err
+		}
+		if !owned {
+			return nil, errors.New("folder not found")
+		}
+	}
+
+	blockedReason, err := c.screenDestination(originalURL)
+	if err != nil {
+		return nil, err
+	}
+	opts.blockedReason = blockedReason
+
+	if opts, err = withPasswordHash(opts); err != nil {
+		return nil, err
+	}
+
+	if opts.RedirectStatus == 0 {
+		if opts.RedirectStatus, err = c.defaultRedirectStatus(userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Alias != "" {
+		return c.reserveAlias(originalURL, userID, opts)
+	}
+
 	const maxAttempts = 10 // Maximum attempts to generate a unique short code
 
 	for attempt := 0; attempt < maxAttempts; attempt++ {
@@ -62,22 +139,17 @@ func (c *URLController) GenerateShortCode(originalURL string, userID uuid.UUID)
 			}
 
 			// Code doesn't exist - create it
-			createdAt := time.Now()
-			expiresAt := createdAt.AddDate(5, 0, 0)
-
-			urlRecord := models.URL{
-				ShortCode:   code,
-				OriginalURL: originalURL,
-				ClickCount:  0,
-				UserID:      userID,
-				CreatedAt:   createdAt,
-				UpdatedAt:   createdAt,
-				ExpiresAt:   &expiresAt,
+			urlRecord, err := newURLRecord(code, originalURL, userID, opts)
+			if err != nil {
+				return nil, err
 			}
-
 			if err := c.DB.Create(&urlRecord).Error; err != nil {
+				if isUniqueViolation(err) {
+					continue // Lost a race for this code, try another one
+				}
 				return nil, err
 			}
+			c.InvalidateCodes(code) // Drop any negative entry from before the code existed
 
 			return &urlRecord, nil
 		}
@@ -89,6 +161,93 @@ func (c *URLController) GenerateShortCode(originalURL string, userID uuid.UUID)
 	return nil, errors.New("failed to generate unique short code after maximum attempts")
 }
 
+// reserveAlias creates a URL using the caller's custom alias as its short code
+func (c *URLController) reserveAlias(originalURL string, userID uuid.UUID, opts ShortenOptions) (*models.URL, error) {
+	if err := util.ValidateAlias(opts.Alias); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := c.DB.Model(&models.URL{}).Where("short_code = ?", opts.Alias).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("alias already in use")
+	}
+
+	urlRecord, err := newURLRecord(opts.Alias, originalURL, userID, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.DB.Create(&urlRecord).Error; err != nil {
+		// The unique index on short_code catches concurrent reservations of the same alias
+		if isUniqueViolation(err) {
+			return nil, errors.New("alias already in use")
+		}
+		return nil, err
+	}
+	c.InvalidateCodes(opts.Alias) // Drop any negative entry from before the alias existed
+
+	return &urlRecord, nil
+}
+
+// newURLRecord builds a URL row from the caller's options
+func newURLRecord(code, originalURL string, userID uuid.UUID, opts ShortenOptions) (models.URL, error) {
+	createdAt := time.Now()
+
+	opts, err := withPasswordHash(opts)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	var blockedAt *time.Time
+	if opts.blockedReason != "" {
+		blockedAt = &createdAt
+	}
+
+	redirectStatus := opts.RedirectStatus
+	if redirectStatus == 0 {
+		redirectStatus = http.StatusFound
+	}
+
+	return models.URL{
+		ShortCode:      code,
+		OriginalURL:    originalURL,
+		Title:          opts.Title,
+		FolderID:       opts.FolderID,
+		ClickCount:     0,
+		UserID:         userID,
+		CreatedAt:      createdAt,
+		UpdatedAt:      createdAt,
+		ExpiresAt:      opts.ExpiresAt,
+		MaxClicks:      opts.MaxClicks,
+		PasswordHash:   opts.passwordHash,
+		DestinationKey: util.DestinationKey(originalURL),
+		BlockedReason:  opts.blockedReason,
+		BlockedAt:      blockedAt,
+		RedirectStatus: redirectStatus,
+	}, nil
+}
+
+// withPasswordHash returns opts with its password hashed, unless there is none or it already is
+func withPasswordHash(opts ShortenOptions) (ShortenOptions, error) {
+	if opts.Password == "" || opts.passwordHash != "" {
+		return opts, nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), 12)
+	if err != nil {
+		return opts, err
+	}
+	opts.passwordHash = string(hash)
+	return opts, nil
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
 // GetURLByCode retrieves a URL by its short code
 func (c *URLController) GetURLByCode(code string) (*models.URL, error) {
 	var urlRecord models.URL
@@ -98,61 +257,233 @@ func (c *URLController) GetURLByCode(code string) (*models.URL, error) {
 	return &urlRecord, nil
 }
 
-// ListURLsByUser retrieves all URLs for a user with visit statistics
-func (c *URLController) ListURLsByUser(userID uuid.UUID) ([]URLSummary, error) {
-	var urls []models.URL
-	if err := c.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&urls).Error; err != nil {
+// visitSummary holds the aggregated visit statistics of one URL
+type visitSummary struct {
+	URLID              uint
+	TotalVisits        int64
+	BotVisits          int64
+	UniqueVisitors     int64
+	DailyUniques       int64
+	LastVisitAt        *time.Time
+	LastVisitUserAgent *string
+}
+
+// ListURLsByUser retrieves one page of a user's URLs with visit statistics, or every URL when
+// the query has no limit. Pages are keyset-paginated on the sort value and ID, and statistics
+// for the page come from two aggregate queries, so the cost does not grow with the number of links
+func (c *URLController) ListURLsByUser(userID uuid.UUID, query ListURLsQuery) (*URLPage, error) {
+	if err := query.Validate(); err != nil {
 		return nil, err
 	}
 
-	summaries := make([]URLSummary, 0, len(urls))
-	for _, urlRecord := range urls {
-		visitCount, err := c.GetVisitCount(urlRecord.ID)
-		if err != nil {
-			visitCount = 0 // Continue even if count fails
-		}
+	now := time.Now()
+	filtered := func() *gorm.DB {
+		return applyURLFilters(c.DB.Table("urls u").Where("u.user_id = ?", userID), query, now)
+	}
 
-		uniqueVisitors, err := c.GetUniqueVisitorCount(urlRecord.ID)
-		if err != nil {
-			uniqueVisitors = 0 // Continue even if count fails
+	var total int64
+	if err := filtered().Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	// Timestamps and click counts sort through separate columns so each scans into its own type
+	keyColumn := "sort_time"
+	if query.Sort == "clicks" {
+		keyColumn = "sort_count"
+	}
+
+	page := c.DB.Table("(?) AS page", filtered().Select(fmt.Sprintf("u.id, %s AS %s", urlSortExpressions[query.Sort], keyColumn)))
+	if query.cursorPayload != nil {
+		value, _ := cursorSortValue(query.cursorPayload.Sort, query.cursorPayload.Value)
+		operator := "<"
+		if query.Order == "asc" {
+			operator = ">"
 		}
+		page = page.Where(fmt.Sprintf("(page.%s, page.id) %s (?, ?)", keyColumn, operator), value, query.cursorPayload.ID)
+	}
+
+	page = page.Order(fmt.Sprintf("page.%[1]s %[2]s, page.id %[2]s", keyColumn, query.Order))
+	if query.Limit > 0 {
+		page = page.Limit(query.Limit + 1)
+	}
+
+	var rows []struct {
+		ID        uint
+		SortTime  *time.Time
+		SortCount *int64
+	}
+	if err := page.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
 
-		latestVisit, err := c.GetLatestVisit(urlRecord.ID)
-		var lastVisitAt *time.Time
-		var lastVisitUserAgent *string
-		if err == nil && latestVisit != nil {
-			lastVisitAt = &latestVisit.CreatedAt
-			ua := latestVisit.UserAgent
-			lastVisitUserAgent = &ua
+	result := &URLPage{URLs: make([]URLSummary, 0), Total: total}
+	if query.Limit > 0 && len(rows) > query.Limit {
+		rows = rows[:query.Limit]
+		last := rows[len(rows)-1]
+		if last.SortCount != nil {
+			result.NextCursor = encodeURLCursor(query.Sort, query.Order, *last.SortCount, last.ID)
+		} else if last.SortTime != nil {
+			result.NextCursor = encodeURLCursor(query.Sort, query.Order, *last.SortTime, last.ID)
 		}
+	}
+	ids := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+
+	summaries, err := c.summarizeURLs(ids)
+	if err != nil {
+		return nil, err
+	}
+	result.URLs = summaries
+
+	return result, nil
+}
 
-		// Use TotalVisits as the source of truth for click count to ensure consistency
-		// TotalVisits is the actual count from url_visits table, which is more reliable
-		// If visitCount doesn't match ClickCount, prefer visitCount (the actual data)
-		displayClickCount := int(visitCount)
-		if displayClickCount == 0 && urlRecord.ClickCount > 0 {
-			// Fallback to stored click_count if visitCount is 0 but click_count exists
-			// This handles edge cases where visits table might be empty
-			displayClickCount = urlRecord.ClickCount
+// summarizeURLs loads the given URLs with their visit statistics, preserving the order of ids
+func (c *URLController) summarizeURLs(ids []uint) ([]URLSummary, error) {
+	summaries := make([]URLSummary, 0, len(ids))
+	if len(ids) == 0 {
+		return summaries, nil
+	}
+
+	var urls []models.URL
+	if err := c.DB.Where("id IN ?", ids).Find(&urls).Error; err != nil {
+		return nil, err
+	}
+	urlsByID := make(map[uint]models.URL, len(urls))
+	for _, urlRecord := range urls {
+		urlsByID[urlRecord.ID] = urlRecord
+	}
+
+	visits, err := c.getVisitSummaries(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := c.getURLTags(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		urlRecord, ok := urlsByID[id]
+		if !ok {
+			continue // Deleted between the page and detail queries
 		}
+		visit := visits[id]
+
+		displayClickCount := displayClicks(visit.TotalVisits-visit.BotVisits, urlRecord)
 
 		summaries = append(summaries, URLSummary{
 			ShortCode:          urlRecord.ShortCode,
 			OriginalURL:        urlRecord.OriginalURL,
+			Title:              urlRecord.Title,
+			FolderID:           urlRecord.FolderID,
+			Tags:               tags[id],
 			ClickCount:         displayClickCount, // Use TotalVisits as source of truth
 			CreatedAt:          urlRecord.CreatedAt,
 			UpdatedAt:          urlRecord.UpdatedAt,
 			ExpiresAt:          urlRecord.ExpiresAt,
-			TotalVisits:        visitCount,
-			UniqueVisitors:     uniqueVisitors,
-			LastVisitAt:        lastVisitAt,
-			LastVisitUserAgent: lastVisitUserAgent,
+			MaxClicks:          urlRecord.MaxClicks,
+			PasswordProtected:  urlRecord.PasswordHash != "",
+			RedirectStatus:     urlRecord.RedirectStatus,
+			BlockedReason:      urlRecord.BlockedReason,
+			TotalVisits:        visit.TotalVisits,
+			BotVisits:          visit.BotVisits,
+			UniqueVisitors:     visit.UniqueVisitors,
+			DailyUniques:       visit.DailyUniques,
+			LastVisitAt:        visit.LastVisitAt,
+			LastVisitUserAgent: visit.LastVisitUserAgent,
 		})
 	}
 
 	return summaries, nil
 }
 
+// displayClicks returns the click count shown for a link: recorded human visits plus clicks imported
+// from another shortener. Links with no recorded visits fall back to the stored click_count,
+// which also covers clicks counted before the visits table existed
+func displayClicks(humanVisits int64, urlRecord models.URL) int {
+	if humanVisits == 0 {
+		return urlRecord.ClickCount
+	}
+	return int(humanVisits) + urlRecord.ImportedClicks
+}
+
+// getVisitSummaries aggregates raw and rolled-up visits for the given URLs, keyed by URL ID
+// The latest raw visit is found with a lateral join on the url_visits(url_id, created_at) index
+func (c *URLController) getVisitSummaries(urlIDs []uint) (map[uint]visitSummary, error) {
+	var raw []visitSummary
+	if err := c.DB.Raw(`
+		SELECT u.id AS url_id,
+			COALESCE(totals.total_visits, 0) AS total_visits,
+			COALESCE(totals.bot_visits, 0) AS bot_visits,
+			COALESCE(totals.unique_visitors, 0) AS unique_visitors,
+			COALESCE(totals.daily_uniques, 0) AS daily_uniques,
+			latest.created_at AS last_visit_at,
+			latest.user_agent AS last_visit_user_agent
+		FROM urls u
+		LEFT JOIN (
+			SELECT v.url_id,
+				COUNT(*) AS total_visits,
+				COUNT(*) FILTER (WHERE v.is_bot) AS bot_visits,
+				COUNT(DISTINCT NULLIF(v.ip_address, '')) FILTER (WHERE NOT v.is_bot) AS unique_visitors,
+				COUNT(DISTINCT to_char(v.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') || NULLIF(v.ip_address, ''))
+					FILTER (WHERE NOT v.is_bot) AS daily_uniques
+			FROM url_visits v
+			WHERE v.url_id IN ?
+			GROUP BY v.url_id
+		) totals ON totals.url_id = u.id
+		LEFT JOIN LATERAL (
+			SELECT created_at, user_agent
+			FROM url_visits
+			WHERE url_id = u.id
+			ORDER BY created_at DESC
+			LIMIT 1
+		) latest ON TRUE
+		WHERE u.id IN ?
+	`, urlIDs, urlIDs).Scan(&raw).Error; err != nil {
+		return nil, err
+	}
+
+	var rolledUp []visitSummary
+	if err := c.DB.Raw(`
+		SELECT s.url_id,
+			SUM(s.total_visits)::bigint AS total_visits,
+			SUM(s.bot_visits)::bigint AS bot_visits,
+			SUM(s.unique_visitors)::bigint AS daily_uniques,
+			MAX(s.last_visit_at) AS last_visit_at,
+			(ARRAY_AGG(s.last_user_agent ORDER BY s.day DESC))[1] AS last_visit_user_agent
+		FROM url_daily_stats s
+		WHERE s.url_id IN ?
+		GROUP BY s.url_id
+	`, urlIDs).Scan(&rolledUp).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[uint]visitSummary, len(raw))
+	for _, row := range raw {
+		summaries[row.URLID] = row
+	}
+	for _, row := range rolledUp {
+		summary := summaries[row.URLID]
+		summary.URLID = row.URLID
+		summary.TotalVisits += row.TotalVisits
+		summary.BotVisits += row.BotVisits
+		// Rolled-up days only keep daily uniques, which cannot be merged into distinct visitors
+		summary.DailyUniques += row.DailyUniques
+		if summary.LastVisitAt == nil {
+			// Raw visits are always newer than rolled-up days
+			summary.LastVisitAt = row.LastVisitAt
+			summary.LastVisitUserAgent = row.LastVisitUserAgent
+		}
+		summaries[row.URLID] = summary
+	}
+
+	return summaries, nil
+}
+
 // DeleteURL deletes a URL if it belongs to the specified user
 func (c *URLController) DeleteURL(code string, userID uuid.UUID) error {
 	var urlRecord models.URL
@@ -170,42 +501,199 @@ func (c *URLController) DeleteURL(code string, userID uuid.UUID) error {
 	if err := c.DB.Delete(&urlRecord).Error; err != nil {
 		return err
 	}
+	c.InvalidateCodes(urlRecord.ShortCode)
 
 	return nil
 }
 
+// URLUpdate describes the changes to apply to an existing URL; nil fields are left unchanged
+type URLUpdate struct {
+	OriginalURL    *string
+	ExpiresAt      *time.Time
+	ClearExpiry    bool    // Remove the expiry so the link never expires
+	MaxClicks      *int    // A value of 0 removes the click limit
+	Password       *string // An empty string removes the password
+	Title          *string // An empty string removes the title
+	FolderID       *uint   // A value of 0 moves the link out of its folder
+	RedirectStatus *int    // 301, 302, 307 or 308
+}
+
+// getOwnedURL loads a URL by code and verifies it belongs to the specified user
+func (c *URLController) getOwnedURL(code string, userID uuid.UUID) (*models.URL, error) {
+	urlRecord, err := c.GetURLByCode(code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("URL not found")
+		}
+		return nil, err
+	}
+
+	if urlRecord.UserID != userID {
+		return nil, errors.New("permission denied")
+	}
+
+	return urlRecord, nil
+}
+
+// UpdateURL applies changes to a URL if it belongs to the specified user
+func (c *URLController) UpdateURL(code string, userID uuid.UUID, update URLUpdate) (*models.URL, error) {
+	urlRecord, err := c.getOwnedURL(code, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]interface{}{}
+	if update.OriginalURL != nil {
+		changes["original_url"] = *update.OriginalURL
+		changes["destination_key"] = util.DestinationKey(*update.OriginalURL)
+
+		blockedReason, err := c.screenDestination(*update.OriginalURL)
+		if err != nil {
+			return nil, err
+		}
+		changes["blocked_reason"] = blockedReason
+		changes["blocked_at"] = nil
+		if blockedReason != "" {
+			changes["blocked_at"] = time.Now()
+		}
+	}
+	if update.Title != nil {
+		changes["title"] = *update.Title
+	}
+	if update.RedirectStatus != nil {
+		changes["redirect_status"] = *update.RedirectStatus
+	}
+	if update.FolderID != nil {
+		if *update.FolderID == 0 {
+			changes["folder_id"] = nil
+		} else {
+			owned, err := c.ownsFolder(userID, *update.FolderID)
+			if err != nil {
+				return nil, err
+			}
+			if !owned {
+				return nil, errors.New("folder not found")
+			}
+			changes["folder_id"] = *update.FolderID
+		}
+	}
+	if update.ExpiresAt != nil {
+		changes["expires_at"] = *update.ExpiresAt
+	} else if update.ClearExpiry {
+		changes["expires_at"] = nil
+	}
+	if update.MaxClicks != nil {
+		if *update.MaxClicks == 0 {
+			changes["max_clicks"] = nil
+		} else {
+			changes["max_clicks"] = *update.MaxClicks
+		}
+	}
+	if update.Password != nil {
+		if *update.Password == "" {
+			changes["password_hash"] = ""
+		} else {
+			hash, err := bcrypt.GenerateFromPassword([]byte(*update.Password), 12)
+			if err != nil {
+				return nil, err
+			}
+			changes["password_hash"] = string(hash)
+		}
+	}
+
+	if len(changes) == 0 {
+		return urlRecord, nil
+	}
+
+	if err := c.DB.Model(urlRecord).Updates(changes).Error; err != nil {
+		return nil, err
+	}
+	c.InvalidateCodes(urlRecord.ShortCode)
+
+	return urlRecord, nil
+}
+
+// CheckURLPassword reports whether password unlocks the given URL
+func (c *URLController) CheckURLPassword(urlRecord *models.URL, password string) bool {
+	if urlRecord.PasswordHash == "" {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(urlRecord.PasswordHash), []byte(password)) == nil
+}
+
 // IncrementClickCount increments the click count for a URL
 func (c *URLController) IncrementClickCount(urlID uint) error {
 	return c.DB.Model(&models.URL{}).Where("id = ?", urlID).UpdateColumn("click_count", gorm.Expr("click_count + ?", 1)).Error
 }
 
-// RecordVisit creates a new visit record for a URL
-func (c *URLController) RecordVisit(urlID uint, ipAddress, userAgent string) error {
-	visit := models.URLVisit{
-		URLID:     urlID,
-		IPAddress: ipAddress,
-		UserAgent: userAgent,
+// newVisitRecord builds a visit row, normalising the Referer header into domain and full URL
+// parsing the User-Agent into browser, OS, device class and bot flag, and resolving the
+// IP address to a country, region and city when a GeoIP database is configured
+// The IP is stored in the form required by the privacy policy; lookups above use the raw address
+func (c *URLController) newVisitRecord(urlID uint, ipAddress, userAgent, referrer string, at time.Time) models.URLVisit {
+	referrerDomain, referrerURL := util.NormalizeReferrer(referrer)
+
+	ua := util.ParseUserAgent(userAgent)
+	if !ua.IsBot && c.Bots != nil {
+		// Custom rules catch monitors and unfurlers the built-in list does not know about
+		if name, ok := c.Bots.Match(userAgent, ipAddress); ok {
+			ua.Browser = name
+			ua.DeviceType = util.DeviceBot
+			ua.IsBot = true
+		}
+	}
+
+	location, _ := c.Geo.Lookup(ipAddress)
+
+	return models.URLVisit{
+		URLID:          urlID,
+		IPAddress:      c.IPs.Anonymize(ipAddress, at),
+		UserAgent:      userAgent,
+		Referrer:       referrerURL,
+		ReferrerDomain: referrerDomain,
+		Browser:        util.TruncateRunes(ua.Browser, util.MaxBrowserLength),
+		OS:             ua.OS,
+		DeviceType:     ua.DeviceType,
+		IsBot:          ua.IsBot,
+		Country:        location.Country,
+		Region:         location.Region,
+		City:           location.City,
+		CreatedAt:      at,
 	}
+}
+
+// RecordVisit creates a new visit record for a URL
+func (c *URLController) RecordVisit(urlID uint, ipAddress, userAgent, referrer string) error {
+	visit := c.newVisitRecord(urlID, ipAddress, userAgent, referrer, time.Now())
 	return c.DB.Create(&visit).Error
 }
 
 // RecordVisitAndIncrement atomically records a visit and increments the click count
 // This ensures both operations succeed or fail together, preventing data inconsistency
-func (c *URLController) RecordVisitAndIncrement(urlID uint, ipAddress, userAgent string) error {
+// Bot visits are stored flagged and never count towards click_count or max_clicks
+// Returns "click limit reached" without recording anything when the URL's max_clicks cap is used up
+func (c *URLController) RecordVisitAndIncrement(urlID uint, ipAddress, userAgent, referrer string) error {
+	visit := c.newVisitRecord(urlID, ipAddress, userAgent, referrer, time.Now())
+
 	// Use a transaction to ensure atomicity
 	return c.DB.Transaction(func(tx *gorm.DB) error {
-		// First, create the visit record
-		visit := models.URLVisit{
-			URLID:     urlID,
-			IPAddress: ipAddress,
-			UserAgent: userAgent,
-		}
-		if err := tx.Create(&visit).Error; err != nil {
-			return err
+		if !visit.IsBot {
+			// First, increment the click count; the guard in the WHERE clause makes the
+			// limit check and the increment a single atomic statement so concurrent
+			// redirects can never push click_count past max_clicks
+			result := tx.Model(&models.URL{}).
+				Where("id = ? AND (max_clicks IS NULL OR click_count < max_clicks)", urlID).
+				UpdateColumn("click_count", gorm.Expr("click_count + ?", 1))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("click limit reached")
+			}
 		}
 
-		// Then, increment the click count
-		if err := tx.Model(&models.URL{}).Where("id = ?", urlID).UpdateColumn("click_count", gorm.Expr("click_count + ?", 1)).Error; err != nil {
+		// Then, create the visit record
+		if err := tx.Create(&visit).Error; err != nil {
 			return err
 		}
 
@@ -213,35 +701,74 @@ func (c *URLController) RecordVisitAndIncrement(urlID uint, ipAddress, userAgent
 	})
 }
 
-// GetVisitCount returns the total number of visits for a URL
+// GetVisitCount returns the total number of visits for a URL, including bot visits
+// Rolled-up days are included alongside the raw visits still in url_visits
 func (c *URLController) GetVisitCount(urlID uint) (int64, error) {
 	var count int64
 	if err := c.DB.Model(&models.URLVisit{}).Where("url_id = ?", urlID).Count(&count).Error; err != nil {
 		return 0, err
 	}
-	return count, nil
+	rolledUp, err := c.sumRolledUp(urlID, "total_visits")
+	if err != nil {
+		return 0, err
+	}
+	return count + rolledUp, nil
+}
+
+// GetBotVisitCount returns the number of visits for a URL that were classified as bots
+func (c *URLController) GetBotVisitCount(urlID uint) (int64, error) {
+	var count int64
+	if err := c.DB.Model(&models.URLVisit{}).Where("url_id = ? AND is_bot", urlID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	rolledUp, err := c.sumRolledUp(urlID, "bot_visits")
+	if err != nil {
+		return 0, err
+	}
+	return count + rolledUp, nil
 }
 
-// GetUniqueVisitorCount returns the number of unique visitors (distinct IP addresses) for a URL
+// GetUniqueVisitorCount returns the number of unique human visitors (distinct IP addresses) for a URL
+// Works on raw, truncated or hashed addresses alike; hashed addresses rotate daily, so a visitor
+// returning on a later day is counted again. Only raw visits are counted, since rolled-up days
+// keep no addresses; see GetDailyUniqueVisitorCount for a count covering them
 func (c *URLController) GetUniqueVisitorCount(urlID uint) (int64, error) {
 	var count int64
 	// Count distinct IP addresses for this URL using PostgreSQL-compatible query
 	// Using Select with Distinct and Count for better compatibility
 	if err := c.DB.Model(&models.URLVisit{}).
-		Where("url_id = ?", urlID).
-		Select("COUNT(DISTINCT ip_address)").
+		Where("url_id = ? AND NOT is_bot", urlID).
+		Select("COUNT(DISTINCT NULLIF(ip_address, ''))").
 		Scan(&count).Error; err != nil {
 		return 0, err
 	}
 	return count, nil
 }
 
+// GetDailyUniqueVisitorCount returns the sum of each UTC day's unique human visitors for a URL
+// A visitor returning on several days is counted once per day, which lets rolled-up days be included
+func (c *URLController) GetDailyUniqueVisitorCount(urlID uint) (int64, error) {
+	var count int64
+	if err := c.DB.Model(&models.URLVisit{}).
+		Where("url_id = ? AND NOT is_bot", urlID).
+		Select(dailyUniquesExpression).
+		Scan(&count).Error; err != nil {
+		return 0, err
+	}
+	rolledUp, err := c.sumRolledUp(urlID, "unique_visitors")
+	if err != nil {
+		return 0, err
+	}
+	return count + rolledUp, nil
+}
+
 // GetLatestVisit returns the most recent visit for a URL
+// When every raw visit has been rolled up, only the time and user agent of the latest one are known
 func (c *URLController) GetLatestVisit(urlID uint) (*models.URLVisit, error) {
 	var visit models.URLVisit
 	if err := c.DB.Where("url_id = ?", urlID).Order("created_at DESC").First(&visit).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil // No visits yet, not an error
+			return c.getLatestRolledUpVisit(urlID) // nil when there are no visits yet
 		}
 		return nil, err
 	}
@@ -272,6 +799,11 @@ func (c *URLController) GetURLStats(code string, userID uuid.UUID) (*URLStats, e
 		return nil, err
 	}
 
+	dailyUniques, err := c.GetDailyUniqueVisitorCount(urlRecord.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	latestVisit, err := c.GetLatestVisit(urlRecord.ID)
 	var lastVisitAt *time.Time
 	var lastVisitUserAgent string
@@ -280,22 +812,58 @@ func (c *URLController) GetURLStats(code string, userID uuid.UUID) (*URLStats, e
 		lastVisitUserAgent = latestVisit.UserAgent
 	}
 
-	// Use TotalVisits as the source of truth for click count to ensure consistency
-	// TotalVisits is the actual count from url_visits table, which is more reliable
-	displayClickCount := int(visitCount)
-	if displayClickCount == 0 && urlRecord.ClickCount > 0 {
-		// Fallback to stored click_count if visitCount is 0 but click_count exists
-		// This handles edge cases where visits table might be empty
-		displayClickCount = urlRecord.ClickCount
+	topReferrers, err := c.GetVisitBreakdown(urlRecord.ID, "referrer_domain", "direct", topBreakdownLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	browsers, err := c.GetVisitBreakdown(urlRecord.ID, "browser", "Other", topBreakdownLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	operatingSystems, err := c.GetVisitBreakdown(urlRecord.ID, "os", "Other", topBreakdownLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceTypes, err := c.GetVisitBreakdown(urlRecord.ID, "device_type", "unknown", topBreakdownLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	countries, err := c.GetVisitBreakdown(urlRecord.ID, "country", "unknown", topBreakdownLimit)
+	if err != nil {
+		return nil, err
 	}
 
+	cities, err := c.GetVisitBreakdown(urlRecord.ID, "city", "unknown", topBreakdownLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	botVisits, err := c.GetBotVisitCount(urlRecord.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	displayClickCount := displayClicks(visitCount-botVisits, *urlRecord)
+
 	return &URLStats{
 		ShortCode:          urlRecord.ShortCode,
 		OriginalURL:        urlRecord.OriginalURL,
 		ClickCount:         displayClickCount, // Use TotalVisits as source of truth
 		TotalVisits:        visitCount,
 		UniqueVisitors:     uniqueVisitors,
+		DailyUniques:       dailyUniques,
 		LastVisitAt:        lastVisitAt,
 		LastVisitUserAgent: lastVisitUserAgent,
+		TopReferrers:       topReferrers,
+		Browsers:           browsers,
+		OperatingSystems:   operatingSystems,
+		DeviceTypes:        deviceTypes,
+		Countries:          countries,
+		Cities:             cities,
+		BotVisits:          botVisits,
 	}, nil
 }
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/google/uuid"
+)
+
+// visitExportBatchSize is the number of visits read from the database per batch during an export
+const visitExportBatchSize = 1000
+
+// ExportURLs passes every link of the user that matches the query's filters to write, one page at a time
+// The query's limit and cursor are ignored; only a single page is held in memory
+func (c *URLController) ExportURLs(userID uuid.UUID, query ListURLsQuery, write func([]URLSummary) error) error {
+	query.Limit = MaxURLPageSize
+	query.Cursor = ""
+
+	for {
+		page, err := c.ListURLsByUser(userID, query)
+		if err != nil {
+			return err
+		}
+		if err := write(page.URLs); err != nil {
+			return err
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// ExportVisits passes the raw visits of a URL the user owns to write, oldest first, in batches
+// Visits already rolled up into daily aggregates are no longer available individually
+// from and to optionally bound created_at (inclusive and exclusive)
+func (c *URLController) ExportVisits(code string, userID uuid.UUID, from, to *time.Time, write func([]models.URLVisit) error) error {
+	urlRecord, err := c.getOwnedURL(code, userID)
+	if err != nil {
+		return err
+	}
+
+	var lastID uint
+	for {
+		query := c.DB.Where("url_id = ? AND id > ?", urlRecord.ID, lastID)
+		if from != nil {
+			query = query.Where("created_at >= ?", *from)
+		}
+		if to != nil {
+			query = query.Where("created_at < ?", *to)
+		}
+
+		// Paging on the primary key keeps each batch an index range scan however far the export gets
+		visits := make([]models.URLVisit, 0, visitExportBatchSize)
+		if err := query.Order("id").Limit(visitExportBatchSize).Find(&visits).Error; err != nil {
+			return err
+		}
+		if err := write(visits); err != nil {
+			return err
+		}
+		if len(visits) < visitExportBatchSize {
+			return nil
+		}
+		lastID = visits[len(visits)-1].ID
+	}
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/Debsnil24/URL_Shortner.git/util"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Idempotency key limits
+const (
+	MaxIdempotencyKeyLength = 255
+	IdempotencyKeyTTL       = 24 * time.Hour // Retries after this create a new link
+	idempotencyClaimTimeout = time.Minute    // An unfinished claim older than this is treated as abandoned
+)
+
+// ReuseMatch lists the options of a shortening request a reused link must share
+// Options the request left out match any link
+type ReuseMatch struct {
+	RedirectStatus int        // 0 matches any status
+	ExpiresAt      *time.Time // Exact expiry asked for; nil matches any
+	NeverExpires   bool       // Only links without an expiry match
+	MaxClicks      *int
+	Title          string
+	FolderID       *uint
+}
+
+// FindReusableURL returns the user's most recent active link to the same destination that agrees
+// with match, or nil when there is none
+// Password-protected links are never reused since the caller would not know the password
+func (c *URLController) FindReusableURL(userID uuid.UUID, originalURL string, match ReuseMatch) (*models.URL, error) {
+	query := c.DB.Where("user_id = ? AND destination_key = ?", userID, util.DestinationKey(originalURL))
+	if match.RedirectStatus != 0 {
+		query = query.Where("redirect_status = ?", match.RedirectStatus)
+	}
+	if match.NeverExpires {
+		query = query.Where("expires_at IS NULL")
+	} else if match.ExpiresAt != nil {
+		query = query.Where("expires_at = ?", *match.ExpiresAt)
+	}
+	if match.MaxClicks != nil {
+		query = query.Where("max_clicks = ?", *match.MaxClicks)
+	}
+	if match.Title != "" {
+		query = query.Where("title = ?", match.Title)
+	}
+	if match.FolderID != nil {
+		query = query.Where("folder_id = ?", *match.FolderID)
+	}
+
+	var urls []models.URL
+	if err := query.
+		Where("COALESCE(password_hash, '') = '' AND COALESCE(blocked_reason, '') = ''").
+		Where("(expires_at IS NULL OR expires_at > ?) AND (max_clicks IS NULL OR click_count < max_clicks)", time.Now()).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&urls).Error; err != nil {
+		return nil, err
+	}
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	return &urls[0], nil
+}
+
+// ClaimIdempotencyKey reserves an Idempotency-Key for a shortening request
+// When an earlier request with the same key and fingerprint already created a link, that link
+// is returned and nothing should be created; otherwise the caller must finish with
+// CompleteIdempotencyKey or ReleaseIdempotencyKey
+func (c *URLController) ClaimIdempotencyKey(userID uuid.UUID, key, fingerprint string) (*models.URL, error) {
+	now := time.Now()
+
+	// Forget expired keys and claims abandoned by a crashed request
+	if err := c.DB.
+		Where("user_id = ? AND (created_at < ? OR (url_id IS NULL AND created_at < ?))", userID, now.Add(-IdempotencyKeyTTL), now.Add(-idempotencyClaimTimeout)).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, err
+	}
+
+	claim := models.IdempotencyKey{UserID: userID, Key: key, Fingerprint: fingerprint}
+	err := c.DB.Create(&claim).Error
+	if err == nil {
+		return nil, nil
+	}
+	if !isUniqueViolation(err) {
+		return nil, err
+	}
+
+	var existing models.IdempotencyKey
+	if err := c.DB.Where("user_id = ? AND key = ?", userID, key).First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("idempotency key is in use by a request in progress")
+		}
+		return nil, err
+	}
+	if existing.Fingerprint != fingerprint {
+		return nil, errors.New("idempotency key was used with a different request")
+	}
+	if existing.URLID == nil {
+		return nil, errors.New("idempotency key is in use by a request in progress")
+	}
+
+	var urlRecord models.URL
+	if err := c.DB.Where("id = ?", *existing.URLID).First(&urlRecord).Error; err != nil {
+		return nil, err
+	}
+	return &urlRecord, nil
+}
+
+// CompleteIdempotencyKey records the link a claimed key's request produced
+func (c *URLController) CompleteIdempotencyKey(userID uuid.UUID, key string, urlID uint) error {
+	return c.DB.Model(&models.IdempotencyKey{}).
+		Where("user_id = ? AND key = ?", userID, key).
+		Update("url_id", urlID).Error
+}
+
+// ReleaseIdempotencyKey drops a claim whose request failed so the client can retry with the same key
+func (c *URLController) ReleaseIdempotencyKey(userID uuid.UUID, key string) error {
+	return c.DB.Where("user_id = ? AND key = ? AND url_id IS NULL", userID, key).Delete(&models.IdempotencyKey{}).Error
+}
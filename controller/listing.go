@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Page size limits for link listings
+const (
+	DefaultURLPageSize = 50
+	MaxURLPageSize     = 200
+)
+
+// displayClicksSQL is displayClicks for the link aliased u: human raw visits plus rolled-up clicks
+// plus imported clicks, falling back to the stored click_count when no visits are recorded
+const displayClicksSQL = `COALESCE(NULLIF(
+		(SELECT COUNT(*) FROM url_visits WHERE url_id = u.id AND NOT is_bot) +
+		(SELECT COALESCE(SUM(clicks), 0) FROM url_daily_stats WHERE url_id = u.id), 0)::bigint + u.imported_clicks,
+	u.click_count)`
+
+// urlSortExpressions maps each listing sort key to the SQL expression it orders by
+// Missing values are replaced by sentinels so keyset comparisons never see NULL:
+// links without an expiry sort after every dated one, never-visited links before every visited one
+// Clicks sort by the count the listing displays
+var urlSortExpressions = map[string]string{
+	"created": "u.created_at",
+	"clicks":  displayClicksSQL,
+	"last_visit": `COALESCE(GREATEST(
+		(SELECT MAX(created_at) FROM url_visits WHERE url_id = u.id),
+		(SELECT MAX(last_visit_at) FROM url_daily_stats WHERE url_id = u.id)
+	), '0001-01-01 00:00:00+00'::timestamptz)`,
+	"expires": "COALESCE(u.expires_at, '9999-12-31 00:00:00+00'::timestamptz)",
+}
+
+// destinationHostSQL extracts the lowercased destination host of "urls u" without credentials,
+// port, query or a leading "www."; it avoids literal question marks, which gorm treats as placeholders
+const destinationHostSQL = `regexp_replace(regexp_replace(regexp_replace(split_part(
+	lower(substring(u.original_url from '^[A-Za-z][A-Za-z0-9+.-]*://([^/#]+)')), chr(63), 1),
+	'^.*@', ''), ':[0-9]*$', ''), '^www\.', '')`
+
+// ListURLsQuery describes the page, order and filters of a link listing
+type ListURLsQuery struct {
+	Limit         int        // Page size; 0 without a cursor lists every link in one response
+	Cursor        string     // Opaque next_cursor from the previous page; empty for the first page
+	Sort          string     // created, clicks, last_visit or expires
+	Order         string     // asc or desc
+	Status        string     // active, expired or empty for all links
+	CreatedFrom   *time.Time // Inclusive lower bound on created_at
+	CreatedTo     *time.Time // Exclusive upper bound on created_at
+	Domain        string     // Destination host; subdomains match too
+	Search        string     // Case-insensitive substring of the short code, destination URL or title
+	TagID         *uint      // Only links carrying this tag
+	FolderID      *uint      // Only links in this folder; 0 selects unfiled links
+	cursorPayload *urlCursor
+}
+
+// URLPage is one page of a user's links
+type URLPage struct {
+	URLs       []URLSummary
+	Total      int64  // Links matching the filters across all pages
+	NextCursor string // Empty on the last page
+}
+
+// urlCursor is the decoded form of a listing cursor: the sort value and ID of the last link on a page
+type urlCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+// Validate fills in defaults and checks the sort, filters and cursor of a listing query
+// Without a limit or cursor the listing is not paginated, as before pagination existed
+func (q *ListURLsQuery) Validate() error {
+	if q.Limit == 0 && q.Cursor != "" {
+		q.Limit = DefaultURLPageSize
+	}
+	if q.Limit < 0 || q.Limit > MaxURLPageSize {
+		return fmt.Errorf("limit must be between 1 and %d", MaxURLPageSize)
+	}
+
+	if q.Sort == "" {
+		q.Sort = "created"
+	}
+	if _, ok := urlSortExpressions[q.Sort]; !ok {
+		return fmt.Errorf("sort must be one of created, clicks, last_visit or expires")
+	}
+
+	if q.Order == "" {
+		q.Order = "desc"
+	}
+	if q.Order != "asc" && q.Order != "desc" {
+		return fmt.Errorf("order must be asc or desc")
+	}
+
+	switch q.Status {
+	case "", "all":
+		q.Status = ""
+	case "active", "expired":
+	default:
+		return fmt.Errorf("status must be one of active, expired or all")
+	}
+
+	if q.CreatedFrom != nil && q.CreatedTo != nil && !q.CreatedFrom.Before(*q.CreatedTo) {
+		return fmt.Errorf("created_from must be before created_to")
+	}
+
+	q.Domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(q.Domain)), "www.")
+	q.Search = strings.TrimSpace(q.Search)
+
+	if q.Cursor != "" {
+		cursor, err := decodeURLCursor(q.Cursor)
+		if err != nil || cursor.Sort != q.Sort || cursor.Order != q.Order {
+			return fmt.Errorf("invalid cursor")
+		}
+		if _, err := cursorSortValue(cursor.Sort, cursor.Value); err != nil {
+			return fmt.Errorf("invalid cursor")
+		}
+		q.cursorPayload = cursor
+	}
+	return nil
+}
+
+// applyURLFilters restricts a query over "urls u" to the listing filters
+func applyURLFilters(db *gorm.DB, q ListURLsQuery, now time.Time) *gorm.DB {
+	const activeCondition = "(u.expires_at IS NULL OR u.expires_at > ?) AND (u.max_clicks IS NULL OR u.click_count < u.max_clicks)"
+
+	switch q.Status {
+	case "active":
+		db = db.Where(activeCondition, now)
+	case "expired":
+		db = db.Where("NOT ("+activeCondition+")", now)
+	}
+
+	if q.CreatedFrom != nil {
+		db = db.Where("u.created_at >= ?", *q.CreatedFrom)
+	}
+	if q.CreatedTo != nil {
+		db = db.Where("u.created_at < ?", *q.CreatedTo)
+	}
+
+	if q.Domain != "" {
+		db = db.Where("("+destinationHostSQL+" = ? OR "+destinationHostSQL+" LIKE ?)", q.Domain, "%."+escapeLike(q.Domain))
+	}
+
+	if q.TagID != nil {
+		db = db.Where("EXISTS (SELECT 1 FROM url_tags ut WHERE ut.url_id = u.id AND ut.tag_id = ?)", *q.TagID)
+	}
+
+	if q.FolderID != nil {
+		if *q.FolderID == 0 {
+			db = db.Where("u.folder_id IS NULL")
+		} else {
+			db = db.Where("u.folder_id = ?", *q.FolderID)
+		}
+	}
+
+	if q.Search != "" {
+		pattern := "%" + escapeLike(q.Search) + "%"
+		db = db.Where("(u.short_code ILIKE ? OR u.original_url ILIKE ? OR u.title ILIKE ?)", pattern, pattern, pattern)
+	}
+
+	return db
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// encodeURLCursor builds the opaque cursor pointing after a link with the given sort value
+func encodeURLCursor(sort, order string, value interface{}, id uint) string {
+	cursor := urlCursor{Sort: sort, Order: order, ID: id}
+	switch v := value.(type) {
+	case time.Time:
+		cursor.Value = v.UTC().Format(time.RFC3339Nano)
+	default:
+		cursor.Value = fmt.Sprint(v)
+	}
+
+	payload, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// decodeURLCursor parses a cursor produced by encodeURLCursor
+func decodeURLCursor(raw string) (*urlCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var cursor urlCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// cursorSortValue converts a cursor's stored sort value back into the type the sort expression compares against
+func cursorSortValue(sort, value string) (interface{}, error) {
+	if sort == "clicks" {
+		return strconv.ParseInt(value, 10, 64)
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
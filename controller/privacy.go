@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/service"
+	"gorm.io/gorm"
+)
+
+// IPSaltStore keeps the daily IP hash salts in the ip_hash_salts table so every instance
+// and restart hashes an address the same way within a UTC day
+type IPSaltStore struct {
+	DB *gorm.DB
+}
+
+// NewIPSaltStore creates a salt store backed by db
+func NewIPSaltStore(db *gorm.DB) *IPSaltStore {
+	return &IPSaltStore{DB: db}
+}
+
+// saltGracePeriod is how long a day's salt outlives the day, so visits queued across midnight
+// are still hashed with it
+const saltGracePeriod = time.Hour
+
+// DaySalt returns the salt stored for day, storing candidate when no instance has yet,
+// and deletes the salts of earlier UTC days that ended more than saltGracePeriod ago
+func (s *IPSaltStore) DaySalt(day string, candidate []byte) ([]byte, error) {
+	var salt []byte
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("INSERT INTO ip_hash_salts (day, salt) VALUES (?, ?) ON CONFLICT (day) DO NOTHING", day, candidate).Error; err != nil {
+			return err
+		}
+		if err := tx.Raw("SELECT salt FROM ip_hash_salts WHERE day = ?", day).Row().Scan(&salt); err != nil {
+			return err
+		}
+		return tx.Exec("DELETE FROM ip_hash_salts WHERE day < ?::date AND day < ?::date",
+			day, time.Now().UTC().Add(-saltGracePeriod).Format("2006-01-02")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(salt) == 0 {
+		return nil, errors.New("salt not stored")
+	}
+	return salt, nil
+}
+
+// AnonymiseStoredIPs rewrites visit IPs stored before IP_PRIVACY_MODE was enabled into the
+// configured form with one UPDATE, and returns the number of rows changed and, in truncate
+// mode, the number of rows skipped because their value is not an IP address
+// It is a one-off job run explicitly by an operator; in raw mode it refuses to run since there
+// is nothing to apply. The rewrite cannot be undone
+func (c *URLController) AnonymiseStoredIPs() (int64, int64, error) {
+	switch c.IPs.Mode() {
+	case service.IPModeTruncate:
+		return c.truncateStoredIPs()
+	case service.IPModeHash:
+		rows, err := c.hashStoredIPs()
+		return rows, 0, err
+	default:
+		return 0, 0, errors.New("IP_PRIVACY_MODE is raw; set it to truncate or hash before anonymising stored IPs")
+	}
+}
+
+// truncateStoredIPs zeroes the host part of stored addresses like live recording does
+// A value that does not parse as an address (such as a forwarded-for list) would make the cast
+// fail for the whole UPDATE, so a session-local function casts each row and such rows are left
+// alone and counted instead
+func (c *URLController) truncateStoredIPs() (int64, int64, error) {
+	var rows, skipped int64
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			CREATE FUNCTION pg_temp.try_inet(value TEXT) RETURNS INET AS $$
+			BEGIN
+				RETURN value::inet;
+			EXCEPTION WHEN others THEN
+				RETURN NULL;
+			END
+			$$ LANGUAGE plpgsql IMMUTABLE
+		`).Error; err != nil {
+			return err
+		}
+
+		result := tx.Exec(`
+			UPDATE url_visits AS v
+			SET ip_address = host(network(set_masklen(parsed.address,
+				CASE WHEN family(parsed.address) = 4 THEN 24 ELSE 48 END)))
+			FROM (SELECT id, pg_temp.try_inet(ip_address) AS address
+				FROM url_visits
+				WHERE ip_address <> '' AND ip_address NOT LIKE 'h:%') AS parsed
+			WHERE v.id = parsed.id AND parsed.address IS NOT NULL
+		`)
+		if result.Error != nil {
+			return result.Error
+		}
+		rows = result.RowsAffected
+
+		return tx.Raw(`
+			SELECT COUNT(*) FROM url_visits
+			WHERE ip_address <> '' AND ip_address NOT LIKE 'h:%' AND pg_temp.try_inet(ip_address) IS NULL
+		`).Scan(&skipped).Error
+	})
+	return rows, skipped, err
+}
+
+// hashStoredIPs replaces stored addresses with the hashes live recording would produce
+// The database computes HMAC-SHA256 from padded keys held in a temporary table; today's rows
+// use the shared salt so they stay countable alongside new visits, while earlier days get
+// random salts that are dropped with the table when the transaction ends
+func (c *URLController) hashStoredIPs() (int64, error) {
+	var rows int64
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		var days []struct {
+			Day string
+		}
+		if err := tx.Raw(`
+			SELECT DISTINCT to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day
+			FROM url_visits
+			WHERE ip_address <> '' AND ip_address NOT LIKE 'h:%'
+		`).Scan(&days).Error; err != nil {
+			return err
+		}
+		if len(days) == 0 {
+			return nil
+		}
+
+		if err := tx.Exec(`
+			CREATE TEMPORARY TABLE ip_hash_keys (
+				day DATE PRIMARY KEY,
+				inner_key BYTEA NOT NULL,
+				outer_key BYTEA NOT NULL
+			) ON COMMIT DROP
+		`).Error; err != nil {
+			return err
+		}
+
+		today := time.Now().UTC().Format("2006-01-02")
+		for _, day := range days {
+			salt := service.NewSalt()
+			if day.Day == today {
+				salt = c.IPs.DaySalt(time.Now())
+			}
+			inner, outer := service.HMACPads(salt)
+			if err := tx.Exec("INSERT INTO ip_hash_keys (day, inner_key, outer_key) VALUES (?, ?, ?)", day.Day, inner, outer).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Exec(`
+			UPDATE url_visits AS v
+			SET ip_address = 'h:' || encode(substring(
+				sha256(k.outer_key || sha256(k.inner_key || convert_to(v.ip_address, 'UTF8')))
+				FROM 1 FOR 16), 'hex')
+			FROM ip_hash_keys AS k
+			WHERE k.day = (v.created_at AT TIME ZONE 'UTC')::date
+				AND v.ip_address <> '' AND v.ip_address NOT LIKE 'h:%'
+		`)
+		rows = result.RowsAffected
+		return result.Error
+	})
+	return rows, err
+}
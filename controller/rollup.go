@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"gorm.io/gorm"
+)
+
+// rollupLockKey is the PostgreSQL advisory lock that keeps concurrent instances from rolling up the same day
+const rollupLockKey = 0x51AB1E
+
+// dailyBreakdownLimit is the number of values kept per breakdown column for each rolled-up day
+const dailyBreakdownLimit = 20
+
+// StartVisitRollup rolls raw visits older than retention into daily aggregates every interval
+func (c *URLController) StartVisitRollup(retention, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			days, err := c.RollupVisits(time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("event=visit_rollup_error err=%v", err)
+			} else if days > 0 {
+				log.Printf("event=visit_rollup_complete days=%d", days)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// RollupVisits aggregates and deletes raw visits from every whole UTC day before cutoff
+// Each day is rolled up in its own transaction and returns the number of days processed
+func (c *URLController) RollupVisits(cutoff time.Time) (int, error) {
+	end := truncateToInterval(cutoff.UTC(), "day")
+
+	days := 0
+	for {
+		var oldest struct {
+			Oldest *time.Time
+		}
+		if err := c.DB.Model(&models.URLVisit{}).
+			Select("MIN(created_at) AS oldest").
+			Where("created_at < ?", end).
+			Scan(&oldest).Error; err != nil {
+			return days, err
+		}
+		if oldest.Oldest == nil {
+			return days, nil
+		}
+
+		day := truncateToInterval(oldest.Oldest.UTC(), "day")
+		locked, err := c.rollupDay(day)
+		if err != nil {
+			return days, err
+		}
+		if !locked {
+			return days, nil // Another instance is rolling up
+		}
+		days++
+	}
+}
+
+// rollupDay moves one UTC day of raw visits into url_daily_stats and url_daily_breakdowns
+// Returns false without doing anything when another instance holds the rollup lock
+func (c *URLController) rollupDay(day time.Time) (bool, error) {
+	next := day.AddDate(0, 0, 1)
+	dayValue := day.Format("2006-01-02")
+
+	locked := false
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", rollupLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		// Adding to an existing row keeps a retried or partial rollup from losing counts
+		if err := tx.Exec(`
+			INSERT INTO url_daily_stats (url_id, day, total_visits, clicks, bot_visits, unique_visitors, last_visit_at, last_user_agent)
+			SELECT url_id, ?::date, COUNT(*),
+				COUNT(*) FILTER (WHERE NOT is_bot),
+				COUNT(*) FILTER (WHERE is_bot),
+				COUNT(DISTINCT NULLIF(ip_address, '')) FILTER (WHERE NOT is_bot),
+				MAX(created_at),
+				(ARRAY_AGG(user_agent ORDER BY created_at DESC))[1]
+			FROM url_visits
+			WHERE created_at >= ? AND created_at < ?
+			GROUP BY url_id
+			ON CONFLICT (url_id, day) DO UPDATE SET
+				total_visits = url_daily_stats.total_visits + EXCLUDED.total_visits,
+				clicks = url_daily_stats.clicks + EXCLUDED.clicks,
+				bot_visits = url_daily_stats.bot_visits + EXCLUDED.bot_visits,
+				-- Late visits of an already rolled-up day mostly come from visitors it counted;
+				-- adding the two distinct counts would count those visitors twice
+				unique_visitors = GREATEST(url_daily_stats.unique_visitors, EXCLUDED.unique_visitors),
+				last_visit_at = GREATEST(url_daily_stats.last_visit_at, EXCLUDED.last_visit_at),
+				last_user_agent = CASE WHEN EXCLUDED.last_visit_at >= url_daily_stats.last_visit_at
+					THEN EXCLUDED.last_user_agent ELSE url_daily_stats.last_user_agent END
+		`, dayValue, day, next).Error; err != nil {
+			return err
+		}
+
+		for column := range breakdownColumns {
+			if err := tx.Exec(fmt.Sprintf(`
+				INSERT INTO url_daily_breakdowns (url_id, day, dimension, value, visits)
+				SELECT url_id, ?::date, ?, value, visits
+				FROM (
+					SELECT url_id, COALESCE(%[1]s, '') AS value, COUNT(*) AS visits,
+						ROW_NUMBER() OVER (PARTITION BY url_id ORDER BY COUNT(*) DESC, COALESCE(%[1]s, '')) AS position
+					FROM url_visits
+					WHERE created_at >= ? AND created_at < ? AND NOT is_bot
+					GROUP BY url_id, COALESCE(%[1]s, '')
+				) ranked
+				WHERE position <= ?
+				ON CONFLICT (url_id, day, dimension, value) DO UPDATE SET
+					visits = url_daily_breakdowns.visits + EXCLUDED.visits
+			`, column), dayValue, column, day, next, dailyBreakdownLimit).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("created_at >= ? AND created_at < ?", day, next).Delete(&models.URLVisit{}).Error
+	})
+	return locked, err
+}
+
+// sumRolledUp totals a url_daily_stats counter column across a URL's rolled-up days
+func (c *URLController) sumRolledUp(urlID uint, column string) (int64, error) {
+	switch column {
+	case "total_visits", "clicks", "bot_visits", "unique_visitors":
+	default:
+		return 0, fmt.Errorf("unsupported rollup column %q", column)
+	}
+
+	var total int64
+	if err := c.DB.Model(&models.URLDailyStat{}).
+		Select(fmt.Sprintf("COALESCE(SUM(%s), 0)::bigint", column)).
+		Where("url_id = ?", urlID).
+		Scan(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// getLatestRolledUpVisit returns the last visit recorded in a URL's rolled-up days, or nil when there are none
+func (c *URLController) getLatestRolledUpVisit(urlID uint) (*models.URLVisit, error) {
+	var stats []models.URLDailyStat
+	if err := c.DB.Where("url_id = ?", urlID).Order("day DESC").Limit(1).Find(&stats).Error; err != nil {
+		return nil, err
+	}
+	if len(stats) == 0 {
+		return nil, nil
+	}
+	return &models.URLVisit{
+		URLID:     urlID,
+		UserAgent: stats[0].LastUserAgent,
+		CreatedAt: stats[0].LastVisitAt,
+	}, nil
+}
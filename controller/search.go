@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Result limits for link search
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// searchDocumentSQL is the full-text document of "urls u"; it must match the
+// idx_urls_search_document expression index for the planner to use it
+const searchDocumentSQL = "to_tsvector('simple', COALESCE(u.title, '') || ' ' || u.original_url)"
+
+// SearchURLs returns the caller's links matching text in their short code, destination URL, title or tags,
+// best matches first. Substring matches use the trigram indexes, whole words the full-text index,
+// and near misses in titles and URLs are caught by trigram word similarity
+func (c *URLController) SearchURLs(userID uuid.UUID, text string, limit int) ([]URLSummary, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if limit < 1 || limit > MaxSearchLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", MaxSearchLimit)
+	}
+
+	pattern := "%" + escapeLike(text) + "%"
+
+	var rows []struct {
+		ID    uint
+		Score float64
+	}
+	if err := c.DB.Table("urls u").
+		Select(`u.id, GREATEST(
+			similarity(u.short_code, ?),
+			word_similarity(?, u.original_url),
+			word_similarity(?, COALESCE(u.title, '')),
+			ts_rank(`+searchDocumentSQL+`, plainto_tsquery('simple', ?))
+		) AS score`, text, text, text, text).
+		Where("u.user_id = ?", userID).
+		Where(`(u.short_code ILIKE ? OR u.original_url ILIKE ? OR u.title ILIKE ?
+			OR `+searchDocumentSQL+` @@ plainto_tsquery('simple', ?)
+			OR ? <% u.title OR ? <% u.original_url
+			OR EXISTS (SELECT 1 FROM url_tags ut JOIN tags t ON t.id = ut.tag_id WHERE ut.url_id = u.id AND t.name ILIKE ?))`,
+			pattern, pattern, pattern, text, text, text, pattern).
+		Order("score DESC, u.created_at DESC, u.id DESC").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	return c.summarizeURLs(ids)
+}
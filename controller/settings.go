@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserSettings are a user's defaults for the links they create
+type UserSettings struct {
+	ReuseExistingLinks    bool // Shortening an identical destination returns the existing active link
+	DefaultRedirectStatus int  // HTTP status of new links that do not choose one
+}
+
+// UserSettingsUpdate describes settings to change; nil fields are left unchanged
+type UserSettingsUpdate struct {
+	ReuseExistingLinks    *bool
+	DefaultRedirectStatus *int
+}
+
+// GetUserSettings loads the link defaults of a user
+func (c *URLController) GetUserSettings(userID uuid.UUID) (*UserSettings, error) {
+	var user models.User
+	if err := c.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	return &UserSettings{
+		ReuseExistingLinks:    user.ReuseExistingLinks,
+		DefaultRedirectStatus: user.DefaultRedirectStatus,
+	}, nil
+}
+
+// UpdateUserSettings changes the link defaults of a user and returns the result
+func (c *URLController) UpdateUserSettings(userID uuid.UUID, update UserSettingsUpdate) (*UserSettings, error) {
+	changes := map[string]interface{}{}
+	if update.ReuseExistingLinks != nil {
+		changes["reuse_existing_links"] = *update.ReuseExistingLinks
+	}
+	if update.DefaultRedirectStatus != nil {
+		changes["default_redirect_status"] = *update.DefaultRedirectStatus
+	}
+
+	if len(changes) > 0 {
+		result := c.DB.Model(&models.User{}).Where("id = ?", userID).Updates(changes)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, errors.New("user not found")
+		}
+	}
+
+	return c.GetUserSettings(userID)
+}
+
+// defaultRedirectStatus returns the redirect status a user's new links get when they do not choose one
+func (c *URLController) defaultRedirectStatus(userID uuid.UUID) (int, error) {
+	settings, err := c.GetUserSettings(userID)
+	if err != nil {
+		return 0, err
+	}
+	if settings.DefaultRedirectStatus == 0 {
+		return http.StatusFound, nil
+	}
+	return settings.DefaultRedirectStatus, nil
+}
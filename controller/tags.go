@@ -0,0 +1,274 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Maximum lengths of tag and folder names
+const (
+	MaxTagNameLength    = 50
+	MaxFolderNameLength = 100
+)
+
+// TagRef identifies a tag attached to a link
+type TagRef struct {
+	ID   uint
+	Name string
+}
+
+// LabelSummary is a tag or folder with totals across the links it holds
+type LabelSummary struct {
+	ID          uint
+	Name        string
+	CreatedAt   time.Time
+	LinkCount   int64
+	TotalClicks int64 // Human clicks across all of the links
+}
+
+// normalizeLabelName trims a tag or folder name and checks its length
+func normalizeLabelName(name string, maxLength int) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("name is required")
+	}
+	if utf8.RuneCountInString(name) > maxLength {
+		return "", fmt.Errorf("name must be at most %d characters", maxLength)
+	}
+	return name, nil
+}
+
+// ListTags returns the user's tags with link counts and total clicks, for per-campaign reporting
+func (c *URLController) ListTags(userID uuid.UUID) ([]LabelSummary, error) {
+	tags := make([]LabelSummary, 0)
+	if err := c.DB.Raw(`
+		SELECT t.id, t.name, t.created_at,
+			COUNT(u.id) AS link_count,
+			COALESCE(SUM(`+displayClicksSQL+`), 0)::bigint AS total_clicks
+		FROM tags t
+		LEFT JOIN url_tags ut ON ut.tag_id = t.id
+		LEFT JOIN urls u ON u.id = ut.url_id
+		WHERE t.user_id = ?
+		GROUP BY t.id
+		ORDER BY lower(t.name)
+	`, userID).Scan(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// CreateTag adds a tag for the user; names are unique per user ignoring case
+func (c *URLController) CreateTag(userID uuid.UUID, name string) (*models.Tag, error) {
+	name, err := normalizeLabelName(name, MaxTagNameLength)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := models.Tag{UserID: userID, Name: name}
+	if err := c.DB.Create(&tag).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.New("tag already exists")
+		}
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// RenameTag changes the name of one of the user's tags
+func (c *URLController) RenameTag(userID uuid.UUID, tagID uint, name string) (*models.Tag, error) {
+	name, err := normalizeLabelName(name, MaxTagNameLength)
+	if err != nil {
+		return nil, err
+	}
+
+	var tag models.Tag
+	if err := c.DB.Where("id = ? AND user_id = ?", tagID, userID).First(&tag).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("tag not found")
+		}
+		return nil, err
+	}
+
+	if err := c.DB.Model(&tag).Update("name", name).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.New("tag already exists")
+		}
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// DeleteTag removes one of the user's tags from every link and deletes it
+func (c *URLController) DeleteTag(userID uuid.UUID, tagID uint) error {
+	result := c.DB.Where("id = ? AND user_id = ?", tagID, userID).Delete(&models.Tag{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("tag not found")
+	}
+	return nil
+}
+
+// SetURLTags replaces the tags of a URL the user owns
+// Every tag must belong to the same user; an empty list removes all tags
+func (c *URLController) SetURLTags(code string, userID uuid.UUID, tagIDs []uint) ([]TagRef, error) {
+	urlRecord, err := c.getOwnedURL(code, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	unique := make(map[uint]bool, len(tagIDs))
+	ids := make([]uint, 0, len(tagIDs))
+	for _, id := range tagIDs {
+		if !unique[id] {
+			unique[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > 0 {
+		var owned int64
+		if err := c.DB.Model(&models.Tag{}).Where("id IN ? AND user_id = ?", ids, userID).Count(&owned).Error; err != nil {
+			return nil, err
+		}
+		if owned != int64(len(ids)) {
+			return nil, errors.New("tag not found")
+		}
+	}
+
+	err = c.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("url_id = ?", urlRecord.ID).Delete(&models.URLTag{}).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		links := make([]models.URLTag, 0, len(ids))
+		for _, id := range ids {
+			links = append(links, models.URLTag{URLID: urlRecord.ID, TagID: id})
+		}
+		return tx.Create(&links).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := c.getURLTags([]uint{urlRecord.ID})
+	if err != nil {
+		return nil, err
+	}
+	return tags[urlRecord.ID], nil
+}
+
+// getURLTags loads the tags of the given URLs, keyed by URL ID and sorted by name
+func (c *URLController) getURLTags(urlIDs []uint) (map[uint][]TagRef, error) {
+	var rows []struct {
+		URLID uint
+		ID    uint
+		Name  string
+	}
+	if err := c.DB.Raw(`
+		SELECT ut.url_id, t.id, t.name
+		FROM url_tags ut
+		JOIN tags t ON t.id = ut.tag_id
+		WHERE ut.url_id IN ?
+		ORDER BY lower(t.name)
+	`, urlIDs).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	tags := make(map[uint][]TagRef, len(urlIDs))
+	for _, row := range rows {
+		tags[row.URLID] = append(tags[row.URLID], TagRef{ID: row.ID, Name: row.Name})
+	}
+	return tags, nil
+}
+
+// ListFolders returns the user's folders with link counts and total clicks
+func (c *URLController) ListFolders(userID uuid.UUID) ([]LabelSummary, error) {
+	folders := make([]LabelSummary, 0)
+	if err := c.DB.Raw(`
+		SELECT f.id, f.name, f.created_at,
+			COUNT(u.id) AS link_count,
+			COALESCE(SUM(`+displayClicksSQL+`), 0)::bigint AS total_clicks
+		FROM folders f
+		LEFT JOIN urls u ON u.folder_id = f.id
+		WHERE f.user_id = ?
+		GROUP BY f.id
+		ORDER BY lower(f.name)
+	`, userID).Scan(&folders).Error; err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+// CreateFolder adds a folder for the user; names are unique per user ignoring case
+func (c *URLController) CreateFolder(userID uuid.UUID, name string) (*models.Folder, error) {
+	name, err := normalizeLabelName(name, MaxFolderNameLength)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := models.Folder{UserID: userID, Name: name}
+	if err := c.DB.Create(&folder).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.New("folder already exists")
+		}
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// RenameFolder changes the name of one of the user's folders
+func (c *URLController) RenameFolder(userID uuid.UUID, folderID uint, name string) (*models.Folder, error) {
+	name, err := normalizeLabelName(name, MaxFolderNameLength)
+	if err != nil {
+		return nil, err
+	}
+
+	var folder models.Folder
+	if err := c.DB.Where("id = ? AND user_id = ?", folderID, userID).First(&folder).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("folder not found")
+		}
+		return nil, err
+	}
+
+	if err := c.DB.Model(&folder).Update("name", name).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.New("folder already exists")
+		}
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// DeleteFolder deletes one of the user's folders; its links become unfiled
+func (c *URLController) DeleteFolder(userID uuid.UUID, folderID uint) error {
+	result := c.DB.Where("id = ? AND user_id = ?", folderID, userID).Delete(&models.Folder{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("folder not found")
+	}
+	return nil
+}
+
+// ownsFolder reports whether a folder exists and belongs to the user
+func (c *URLController) ownsFolder(userID uuid.UUID, folderID uint) (bool, error) {
+	var count int64
+	if err := c.DB.Model(&models.Folder{}).Where("id = ? AND user_id = ?", folderID, userID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
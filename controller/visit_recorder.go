@@ -0,0 +1,308 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// visitBatchAttempts is how many times a batch is written before its visits are given up on
+const visitBatchAttempts = 3
+
+// VisitRecorderOptions sizes an asynchronous visit recorder
+type VisitRecorderOptions struct {
+	QueueSize     int           // Visits that can wait to be written
+	Workers       int           // Goroutines writing batches
+	BatchSize     int           // Largest batch written in one transaction
+	FlushInterval time.Duration // Longest a queued visit waits before its batch is written
+}
+
+// VisitRecorderStats is a snapshot of the recorder's queue and counters
+type VisitRecorderStats struct {
+	QueueLength   int
+	QueueCapacity int
+	Workers       int
+	Enqueued      uint64 // Visits accepted by the queue
+	Recorded      uint64 // Visits written by the workers
+	Overflowed    uint64 // Visits recorded synchronously because the queue was full
+	Failed        uint64 // Visits lost after every write attempt failed
+	Batches       uint64 // Transactions committed by the workers
+}
+
+// pendingVisit is a redirect whose visit has not been written yet
+type pendingVisit struct {
+	urlID     uint
+	ipAddress string
+	userAgent string
+	referrer  string
+	at        time.Time
+}
+
+// VisitRecorder writes redirect visits in the background so redirects do not wait on the database
+// Workers drain a bounded queue, insert each batch of visits in one statement and add the
+// batch's human clicks to click_count with one UPDATE per link
+type VisitRecorder struct {
+	controller *URLController
+	opts       VisitRecorderOptions
+	queue      chan pendingVisit
+	wg         sync.WaitGroup
+
+	mu     sync.RWMutex // Guards closed so nothing is sent on the closed queue
+	closed bool
+
+	enqueued   atomic.Uint64
+	recorded   atomic.Uint64
+	overflowed atomic.Uint64
+	failed     atomic.Uint64
+	batches    atomic.Uint64
+}
+
+// activeVisitRecorder is the recorder used by every URLController once started
+var activeVisitRecorder atomic.Pointer[VisitRecorder]
+
+// StartVisitRecorder starts the background visit workers and routes redirect visits through them
+// Until it is called, and after Close, visits are recorded synchronously
+func (c *URLController) StartVisitRecorder(opts VisitRecorderOptions) *VisitRecorder {
+	r := &VisitRecorder{
+		controller: c,
+		opts:       opts,
+		queue:      make(chan pendingVisit, opts.QueueSize),
+	}
+	for i := 0; i < opts.Workers; i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+	activeVisitRecorder.Store(r)
+
+	log.Printf("event=visit_recorder_started queue_size=%d workers=%d batch_size=%d flush_interval=%s",
+		opts.QueueSize, opts.Workers, opts.BatchSize, opts.FlushInterval)
+	return r
+}
+
+// ActiveVisitRecorder returns the running visit recorder, or nil when visits are recorded synchronously
+func ActiveVisitRecorder() *VisitRecorder {
+	return activeVisitRecorder.Load()
+}
+
+// RecordRedirect records the visit of a redirect that is about to be issued
+// Visits are queued for the background workers when possible; links with a click limit are
+// always recorded synchronously so max_clicks stays exact, as are visits arriving while the
+// queue is full. Returns "click limit reached" like RecordVisitAndIncrement
+func (c *URLController) RecordRedirect(urlRecord *models.URL, ipAddress, userAgent, referrer string) error {
+	if recorder := activeVisitRecorder.Load(); recorder != nil && urlRecord.MaxClicks == nil {
+		if recorder.enqueue(pendingVisit{
+			urlID:     urlRecord.ID,
+			ipAddress: ipAddress,
+			userAgent: userAgent,
+			referrer:  referrer,
+			at:        time.Now(),
+		}) {
+			return nil
+		}
+	}
+	return c.RecordVisitAndIncrement(urlRecord.ID, ipAddress, userAgent, referrer)
+}
+
+// enqueue adds a visit to the queue without blocking and reports whether it was accepted
+func (r *VisitRecorder) enqueue(visit pendingVisit) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return false
+	}
+
+	select {
+	case r.queue <- visit:
+		r.enqueued.Add(1)
+		return true
+	default:
+		// Back-pressure: the caller records synchronously, slowing redirects instead of losing visits
+		if n := r.overflowed.Add(1); n == 1 || n%1000 == 0 {
+			log.Printf("event=visit_queue_full capacity=%d overflowed=%d", cap(r.queue), n)
+		}
+		return false
+	}
+}
+
+// Stats returns the current queue length and counters
+func (r *VisitRecorder) Stats() VisitRecorderStats {
+	return VisitRecorderStats{
+		QueueLength:   len(r.queue),
+		QueueCapacity: cap(r.queue),
+		Workers:       r.opts.Workers,
+		Enqueued:      r.enqueued.Load(),
+		Recorded:      r.recorded.Load(),
+		Overflowed:    r.overflowed.Load(),
+		Failed:        r.failed.Load(),
+		Batches:       r.batches.Load(),
+	}
+}
+
+// Close stops accepting visits and waits for the workers to write everything already queued
+// Returns the context's error if it ends before the queue is drained
+func (r *VisitRecorder) Close(ctx context.Context) error {
+	activeVisitRecorder.CompareAndSwap(r, nil)
+
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		stats := r.Stats()
+		log.Printf("event=visit_recorder_stopped recorded=%d overflowed=%d failed=%d", stats.Recorded, stats.Overflowed, stats.Failed)
+		return nil
+	case <-ctx.Done():
+		log.Printf("event=visit_recorder_error reason=shutdown_timeout queued=%d", len(r.queue))
+		return ctx.Err()
+	}
+}
+
+// work collects queued visits into batches, writing a batch once it is full or the flush interval passes
+func (r *VisitRecorder) work() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]pendingVisit, 0, r.opts.BatchSize)
+	for {
+		select {
+		case visit, ok := <-r.queue:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, visit)
+			if len(batch) >= r.opts.BatchSize {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush writes one batch, retrying failed attempts
+func (r *VisitRecorder) flush(batch []pendingVisit) {
+	if len(batch) == 0 {
+		return
+	}
+
+	visits := make([]models.URLVisit, 0, len(batch))
+	for _, pending := range batch {
+		visits = append(visits, r.controller.newVisitRecord(pending.urlID, pending.ipAddress, pending.userAgent, pending.referrer, pending.at))
+	}
+
+	var err error
+	for attempt := 1; attempt <= visitBatchAttempts; attempt++ {
+		if err = r.writeBatch(visits); err == nil {
+			r.recorded.Add(uint64(len(visits)))
+			r.batches.Add(1)
+			return
+		}
+
+		if isForeignKeyViolation(err) {
+			// Links deleted after their redirect was queued; their visits can be dropped
+			visits, err = r.controller.dropVisitsOfDeletedURLs(visits)
+			if err != nil {
+				break
+			}
+			if len(visits) == 0 {
+				return
+			}
+			continue
+		}
+		time.Sleep(time.Duration(attempt) * 200 * time.Millisecond)
+	}
+
+	r.failed.Add(uint64(len(visits)))
+	log.Printf("event=visit_batch_error visits=%d err=%v", len(visits), err)
+}
+
+// writeBatch inserts the visits and applies their click increments in one transaction
+// Links are updated in ID order so concurrent batches cannot deadlock on click_count
+func (r *VisitRecorder) writeBatch(visits []models.URLVisit) error {
+	clicks := make(map[uint]int)
+	for _, visit := range visits {
+		if !visit.IsBot {
+			clicks[visit.URLID]++
+		}
+	}
+	urlIDs := make([]uint, 0, len(clicks))
+	for urlID := range clicks {
+		urlIDs = append(urlIDs, urlID)
+	}
+	sort.Slice(urlIDs, func(i, j int) bool { return urlIDs[i] < urlIDs[j] })
+
+	for i := range visits {
+		visits[i].ID = 0 // Set by a previous attempt whose transaction rolled back
+	}
+
+	return r.controller.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&visits).Error; err != nil {
+			return err
+		}
+		for _, urlID := range urlIDs {
+			if err := tx.Model(&models.URL{}).
+				Where("id = ?", urlID).
+				UpdateColumn("click_count", gorm.Expr("click_count + ?", clicks[urlID])).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// dropVisitsOfDeletedURLs returns the visits whose link still exists
+func (c *URLController) dropVisitsOfDeletedURLs(visits []models.URLVisit) ([]models.URLVisit, error) {
+	urlIDs := make([]uint, 0, len(visits))
+	for _, visit := range visits {
+		urlIDs = append(urlIDs, visit.URLID)
+	}
+
+	var existing []uint
+	if err := c.DB.Model(&models.URL{}).Where("id IN ?", uniqueIDs(urlIDs)).Pluck("id", &existing).Error; err != nil {
+		return nil, err
+	}
+	exists := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		exists[id] = true
+	}
+
+	kept := make([]models.URLVisit, 0, len(visits))
+	for _, visit := range visits {
+		if exists[visit.URLID] {
+			kept = append(kept, visit)
+		}
+	}
+	return kept, nil
+}
+
+// isForeignKeyViolation reports whether err is a PostgreSQL foreign key violation
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/controller"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultTimeSeriesSpans is the range covered when the caller omits "from"
+var defaultTimeSeriesSpans = map[string]func(time.Time) time.Time{
+	"hour":  func(to time.Time) time.Time { return to.Add(-24 * time.Hour) },
+	"day":   func(to time.Time) time.Time { return to.AddDate(0, 0, -30) },
+	"week":  func(to time.Time) time.Time { return to.AddDate(0, 0, -12*7) },
+	"month": func(to time.Time) time.Time { return to.AddDate(-1, 0, 0) },
+}
+
+// parseRangeBound accepts an RFC 3339 timestamp or a YYYY-MM-DD date in the given location
+func parseRangeBound(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected RFC 3339 or YYYY-MM-DD", value)
+}
+
+// GetURLTimeSeries returns clicks and unique visitors bucketed by hour, day, week or month
+func (h *Handler) GetURLTimeSeries(c *gin.Context) {
+	code := c.Param("code")
+
+	// Get userID from context (set by AuthRequired middleware)
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone"})
+		return
+	}
+
+	query := controller.TimeSeriesQuery{
+		Interval: c.DefaultQuery("interval", "day"),
+		To:       time.Now(),
+		Location: loc,
+	}
+
+	if to := c.Query("to"); to != "" {
+		if query.To, err = parseRangeBound(to, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if from := c.Query("from"); from != "" {
+		if query.From, err = parseRangeBound(from, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if span, ok := defaultTimeSeriesSpans[query.Interval]; ok {
+		query.From = span(query.To)
+	}
+
+	if err := query.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Use controller to build the time series (includes ownership check)
+	series, err := h.urlController.GetURLTimeSeries(code, userID, query)
+	if err != nil {
+		if err.Error() == "URL not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+			return
+		}
+		if err.Error() == "permission denied" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to view this URL"})
+			return
+		}
+		log.Printf("event=url_timeseries_error code=%s err=%v", code, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch statistics"})
+		return
+	}
+
+	type bucket struct {
+		Start          time.Time `json:"start"`
+		Clicks         *int64    `json:"clicks"`          // null when the hour was rolled up
+		UniqueVisitors *int64    `json:"unique_visitors"` // Summed daily uniques when rolled up
+		RolledUp       bool      `json:"rolled_up,omitempty"`
+	}
+
+	buckets := make([]bucket, 0, len(series.Buckets))
+	for _, b := range series.Buckets {
+		entry := bucket{Start: b.Start, RolledUp: b.RolledUp}
+		if !b.RolledUp || series.Interval != "hour" {
+			clicks, uniqueVisitors := b.Clicks, b.UniqueVisitors
+			entry.Clicks, entry.UniqueVisitors = &clicks, &uniqueVisitors
+		}
+		buckets = append(buckets, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "OK",
+		"data": gin.H{
+			"short_code":            series.ShortCode,
+			"interval":              series.Interval,
+			"timezone":              series.Timezone,
+			"from":                  series.From,
+			"to":                    series.To,
+			"total_clicks":          series.TotalClicks,
+			"unique_visitors":       series.UniqueVisitors,
+			"daily_unique_visitors": series.DailyUniques,
+			"buckets":               buckets,
+		},
+	})
+}
+
+// breakdownResponse renders breakdown entries as a list of {<key>: value, "visits": n} objects
+func breakdownResponse(entries []controller.BreakdownEntry, key string) []gin.H {
+	response := make([]gin.H, 0, len(entries))
+	for _, entry := range entries {
+		response = append(response, gin.H{key: entry.Value, "visits": entry.Visits})
+	}
+	return response
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/config"
+	"github.com/Debsnil24/URL_Shortner.git/controller"
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/Debsnil24/URL_Shortner.git/util"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// bulkItemResult is the JSON form of one bulk item's outcome
+type bulkItemResult struct {
+	Index          int        `json:"index"`
+	Success        bool       `json:"success"`
+	Error          string     `json:"error,omitempty"`
+	ErrorCode      string     `json:"error_code,omitempty"` // Set when the destination URL was rejected
+	ShortenedURL   string     `json:"shortened_url,omitempty"`
+	OriginalURL    string     `json:"original_url"`
+	ShortCode      string     `json:"short_code,omitempty"`
+	Title          string     `json:"title,omitempty"`
+	FolderID       *uint      `json:"folder_id,omitempty"`
+	Tags           []gin.H    `json:"tags,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	RedirectStatus int        `json:"redirect_status,omitempty"`
+}
+
+// ShortenURLsBulk creates up to controller.MaxBulkShortenItems links in one request
+// Items are validated and created independently; each result carries its own error
+func (h *Handler) ShortenURLsBulk(c *gin.Context) {
+	var req models.BulkShortenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.URLs) > controller.MaxBulkShortenItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d URLs can be shortened per request", controller.MaxBulkShortenItems)})
+		return
+	}
+
+	protected := 0
+	for _, entry := range req.URLs {
+		if entry.Password != "" {
+			protected++
+		}
+	}
+	if protected > controller.MaxBulkPasswordItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d password-protected URLs can be shortened per request", controller.MaxBulkPasswordItems)})
+		return
+	}
+
+	// Get userID from context (set by AuthRequired middleware)
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	results := make([]bulkItemResult, len(req.URLs))
+	items := make([]controller.BulkShortenItem, 0, len(req.URLs))
+	positions := make([]int, 0, len(req.URLs)) // Request index of each item passed to the controller
+	now := time.Now()
+	for i, entry := range req.URLs {
+		results[i] = bulkItemResult{Index: i, OriginalURL: entry.URL}
+
+		item, err := prepareBulkItem(entry, now)
+		if err != nil {
+			results[i].Error = err.Error()
+			results[i].ErrorCode = destinationErrorCode(err)
+			continue
+		}
+		results[i].OriginalURL = item.OriginalURL
+		items = append(items, item)
+		positions = append(positions, i)
+	}
+
+	created, err := h.urlController.GenerateShortCodes(userID, items)
+	if err != nil {
+		log.Printf("event=bulk_shorten_error user_id=%s items=%d err=%v", userID, len(items), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shortened URLs"})
+		return
+	}
+
+	succeeded := 0
+	for n, outcome := range created {
+		result := &results[positions[n]]
+		if outcome.Err != nil {
+			result.Error = bulkItemError(outcome.Err)
+			result.ErrorCode = destinationErrorCode(outcome.Err)
+			if result.Error == "" {
+				log.Printf("event=bulk_shorten_item_error user_id=%s index=%d err=%v", userID, result.Index, outcome.Err)
+				result.Error = "Failed to create shortened URL"
+			}
+			continue
+		}
+
+		result.Success = true
+		result.ShortenedURL = "https://www.sniply.co.in/" + outcome.URL.ShortCode
+		result.ShortCode = outcome.URL.ShortCode
+		result.Title = outcome.URL.Title
+		result.FolderID = outcome.URL.FolderID
+		result.Tags = tagsResponse(outcome.Tags)
+		result.ExpiresAt = outcome.URL.ExpiresAt
+		result.RedirectStatus = outcome.URL.RedirectStatus
+		succeeded++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("%d of %d URLs shortened", succeeded, len(req.URLs)),
+		"data": gin.H{
+			"results":   results,
+			"succeeded": succeeded,
+			"failed":    len(req.URLs) - succeeded,
+		},
+	})
+}
+
+// prepareBulkItem validates one bulk item the same way ShortenURL validates a single request
+func prepareBulkItem(entry models.BulkShortenItem, now time.Time) (controller.BulkShortenItem, error) {
+	if err := binding.Validator.ValidateStruct(&entry.ShortenURLRequest); err != nil {
+		return controller.BulkShortenItem{}, err
+	}
+	if err := validateLinkPassword(entry.Password); err != nil {
+		return controller.BulkShortenItem{}, err
+	}
+
+	originalURL, err := config.DestinationPolicy.Normalize(entry.URL)
+	if err != nil {
+		return controller.BulkShortenItem{}, err
+	}
+
+	alias := strings.TrimSpace(entry.Alias)
+	if alias != "" {
+		if err := util.ValidateAlias(alias); err != nil {
+			return controller.BulkShortenItem{}, err
+		}
+	}
+
+	expiresAt, err := config.LinkExpiryPolicy.Resolve(util.ExpiryRequest{
+		ExpiresAt:    entry.ExpiresAt,
+		TTL:          entry.TTL,
+		NeverExpires: entry.NeverExpires,
+	}, now)
+	if err != nil {
+		return controller.BulkShortenItem{}, err
+	}
+
+	opts := controller.ShortenOptions{
+		Alias:     alias,
+		ExpiresAt: expiresAt,
+		MaxClicks: entry.MaxClicks,
+		Password:  entry.Password,
+		Title:     strings.TrimSpace(entry.Title),
+		FolderID:  entry.FolderID,
+	}
+	if entry.RedirectStatus != nil {
+		opts.RedirectStatus = *entry.RedirectStatus
+	}
+
+	return controller.BulkShortenItem{
+		OriginalURL: originalURL,
+		Options:     opts,
+		TagIDs:      entry.TagIDs,
+	}, nil
+}
+
+// bulkItemError returns the message reported for a per-item controller error,
+// or an empty string when the error is internal and should not be shown
+func bulkItemError(err error) string {
+	switch err.Error() {
+	case "alias already in use":
+		return "Alias is already in use"
+	case "folder not found":
+		return "Folder not found"
+	case "tag not found":
+		return "Tag not found"
+	}
+	if strings.HasPrefix(err.Error(), "alias ") || strings.HasPrefix(err.Error(), "tag ") {
+		return err.Error() // Alias or tag name validation message
+	}
+	if destinationErrorCode(err) != "" {
+		return err.Error() // Destination rejected by the blocklist
+	}
+	return ""
+}
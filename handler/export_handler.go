@@ -0,0 +1,318 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/controller"
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/Debsnil24/URL_Shortner.git/util"
+	"github.com/gin-gonic/gin"
+)
+
+// linkExportColumns are the CSV columns of a link export; they match the import column names
+var linkExportColumns = []string{
+	"short_code", "original_url", "title", "folder_id", "tags", "click_count", "created_at", "updated_at",
+	"expires_at", "max_clicks", "password_protected", "total_visits", "bot_visits", "unique_visitors",
+	"daily_unique_visitors", "last_visit_at", "last_visit_user_agent",
+}
+
+// visitExportColumns are the CSV columns of a visit export
+var visitExportColumns = []string{
+	"id", "created_at", "ip_address", "user_agent", "referrer", "referrer_domain", "browser", "os",
+	"device_type", "is_bot", "country", "region", "city",
+}
+
+// visitExport is the NDJSON form of a raw visit
+type visitExport struct {
+	ID             uint      `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	IPAddress      string    `json:"ip_address"`
+	UserAgent      string    `json:"user_agent"`
+	Referrer       string    `json:"referrer"`
+	ReferrerDomain string    `json:"referrer_domain"`
+	Browser        string    `json:"browser"`
+	OS             string    `json:"os"`
+	DeviceType     string    `json:"device_type"`
+	IsBot          bool      `json:"is_bot"`
+	Country        string    `json:"country"`
+	Region         string    `json:"region"`
+	City           string    `json:"city"`
+}
+
+// exportWriter streams CSV or NDJSON records to the response
+// Headers are sent on the first write so errors before any data can still return a JSON error
+type exportWriter struct {
+	c        *gin.Context
+	format   string // csv or ndjson
+	filename string // Without extension
+	columns  []string
+	started  bool
+	csv      *csv.Writer
+	json     *json.Encoder
+}
+
+// newExportWriter reads the format query parameter, which defaults to csv
+func newExportWriter(c *gin.Context, filename string, columns []string) (*exportWriter, error) {
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "ndjson" {
+		return nil, errors.New("format must be csv or ndjson")
+	}
+	return &exportWriter{c: c, format: format, filename: filename, columns: columns}, nil
+}
+
+// begin sends the response headers and, for CSV, the header row
+func (w *exportWriter) begin() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+
+	contentType := "text/csv; charset=utf-8"
+	if w.format == "ndjson" {
+		contentType = "application/x-ndjson"
+	}
+	w.c.Header("Content-Type", contentType)
+	w.c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, w.filename, w.format))
+	w.c.Header("Cache-Control", "no-store")
+	w.c.Status(http.StatusOK)
+
+	if w.format == "ndjson" {
+		w.json = json.NewEncoder(w.c.Writer)
+		return nil
+	}
+	w.csv = csv.NewWriter(w.c.Writer)
+	return w.csv.Write(w.columns)
+}
+
+// write sends one record: row for CSV, object for NDJSON
+func (w *exportWriter) write(row []string, object interface{}) error {
+	if err := w.begin(); err != nil {
+		return err
+	}
+	if w.format == "ndjson" {
+		return w.json.Encode(object)
+	}
+	return w.csv.Write(row)
+}
+
+// flush pushes buffered records to the client
+func (w *exportWriter) flush() error {
+	if err := w.begin(); err != nil {
+		return err
+	}
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+// fail reports an export error: as a JSON response when nothing was sent yet, otherwise by aborting the stream
+func (w *exportWriter) fail(event string, userID fmt.Stringer, err error, status int, message string) {
+	log.Printf("event=%s user_id=%s streamed=%t err=%v", event, userID, w.started, err)
+	if !w.started {
+		w.c.JSON(status, gin.H{"error": message})
+		return
+	}
+	w.c.Abort()
+}
+
+// ExportURLs streams the caller's links with their statistics as CSV or NDJSON
+// It accepts the same sort and filter parameters as ListURLs
+func (h *Handler) ExportURLs(c *gin.Context) {
+	// Get userID from context (set by AuthRequired middleware)
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	query, err := parseListURLsQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	writer, err := newExportWriter(c, "links-"+time.Now().UTC().Format("20060102"), linkExportColumns)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.urlController.ExportURLs(userID, query, func(summaries []controller.URLSummary) error {
+		for _, summary := range urlSummariesResponse(summaries) {
+			if err := writer.write(linkExportRow(summary), summary); err != nil {
+				return err
+			}
+		}
+		return writer.flush()
+	})
+	if err != nil {
+		writer.fail("export_urls_error", userID, err, http.StatusInternalServerError, "Failed to export URLs")
+	}
+}
+
+// ExportVisits streams the raw visits of one of the caller's links as CSV or NDJSON
+// Optional from and to parameters bound the visit time like the time series endpoint
+func (h *Handler) ExportVisits(c *gin.Context) {
+	code := c.Param("code")
+
+	// Get userID from context (set by AuthRequired middleware)
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var from, to *time.Time
+	if value := c.Query("from"); value != "" {
+		bound, err := parseRangeBound(value, time.UTC)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		from = &bound
+	}
+	if value := c.Query("to"); value != "" {
+		bound, err := parseRangeBound(value, time.UTC)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		to = &bound
+	}
+
+	writer, err := newExportWriter(c, "visits-"+code+"-"+time.Now().UTC().Format("20060102"), visitExportColumns)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.urlController.ExportVisits(code, userID, from, to, func(visits []models.URLVisit) error {
+		for _, visit := range visits {
+			if err := writer.write(visitExportRow(visit), visitExportObject(visit)); err != nil {
+				return err
+			}
+		}
+		return writer.flush()
+	})
+	if err != nil {
+		switch err.Error() {
+		case "URL not found":
+			writer.fail("export_visits_error", userID, err, http.StatusNotFound, "URL not found")
+		case "permission denied":
+			writer.fail("export_visits_error", userID, err, http.StatusForbidden, "You do not have permission to view this URL")
+		default:
+			writer.fail("export_visits_error", userID, err, http.StatusInternalServerError, "Failed to export visits")
+		}
+	}
+}
+
+// linkExportRow formats a link as a CSV row in linkExportColumns order
+func linkExportRow(summary urlSummary) []string {
+	tags := make([]string, 0, len(summary.Tags))
+	for _, tag := range summary.Tags {
+		tags = append(tags, fmt.Sprint(tag["name"]))
+	}
+
+	folderID := ""
+	if summary.FolderID != nil {
+		folderID = strconv.FormatUint(uint64(*summary.FolderID), 10)
+	}
+	maxClicks := ""
+	if summary.MaxClicks != nil {
+		maxClicks = strconv.Itoa(*summary.MaxClicks)
+	}
+	lastVisitUserAgent := ""
+	if summary.LastVisitUserAgent != nil {
+		lastVisitUserAgent = *summary.LastVisitUserAgent
+	}
+
+	return []string{
+		summary.ShortCode,
+		summary.OriginalURL,
+		csvCell(summary.Title),
+		folderID,
+		csvCell(strings.Join(tags, ", ")),
+		strconv.Itoa(summary.ClickCount),
+		formatExportTime(&summary.CreatedAt),
+		formatExportTime(&summary.UpdatedAt),
+		formatExportTime(summary.ExpiresAt),
+		maxClicks,
+		strconv.FormatBool(summary.PasswordProtected),
+		strconv.FormatInt(summary.TotalVisits, 10),
+		strconv.FormatInt(summary.BotVisits, 10),
+		strconv.FormatInt(summary.UniqueVisitors, 10),
+		strconv.FormatInt(summary.DailyUniques, 10),
+		formatExportTime(summary.LastVisitAt),
+		csvCell(lastVisitUserAgent),
+	}
+}
+
+// visitExportRow formats a visit as a CSV row in visitExportColumns order
+func visitExportRow(visit models.URLVisit) []string {
+	return []string{
+		strconv.FormatUint(uint64(visit.ID), 10),
+		formatExportTime(&visit.CreatedAt),
+		visit.IPAddress,
+		csvCell(visit.UserAgent),
+		csvCell(visit.Referrer),
+		csvCell(visit.ReferrerDomain),
+		csvCell(visit.Browser),
+		visit.OS,
+		visit.DeviceType,
+		strconv.FormatBool(visit.IsBot),
+		visit.Country,
+		csvCell(visit.Region),
+		csvCell(visit.City),
+	}
+}
+
+// csvCell guards a caller- or visitor-supplied value against formula injection when the CSV is
+// opened in a spreadsheet: a value starting with =, +, -, @, tab or carriage return is prefixed
+// with a single quote so it is shown as text. Link imports strip the quote again, and NDJSON
+// exports carry the values unchanged
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune(util.CSVFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// visitExportObject converts a visit to its NDJSON form
+func visitExportObject(visit models.URLVisit) visitExport {
+	return visitExport{
+		ID:             visit.ID,
+		CreatedAt:      visit.CreatedAt,
+		IPAddress:      visit.IPAddress,
+		UserAgent:      visit.UserAgent,
+		Referrer:       visit.Referrer,
+		ReferrerDomain: visit.ReferrerDomain,
+		Browser:        visit.Browser,
+		OS:             visit.OS,
+		DeviceType:     visit.DeviceType,
+		IsBot:          visit.IsBot,
+		Country:        visit.Country,
+		Region:         visit.Region,
+		City:           visit.City,
+	}
+}
+
+// formatExportTime formats a timestamp as RFC 3339 in UTC, or an empty string when nil
+func formatExportTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
@@ -1,15 +1,24 @@
 package handler
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Debsnil24/URL_Shortner.git/config"
 	"github.com/Debsnil24/URL_Shortner.git/controller"
 	"github.com/Debsnil24/URL_Shortner.git/models"
 	"github.com/Debsnil24/URL_Shortner.git/service"
+	"github.com/Debsnil24/URL_Shortner.git/util"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -73,11 +82,18 @@ func (h *Handler) ShortenURL(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateLinkPassword(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Validate URL format
-	if !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
-		req.URL = "https://" + req.URL
+	// Normalise the destination and reject unsafe or unsupported URLs
+	normalized, err := config.DestinationPolicy.Normalize(req.URL)
+	if err != nil {
+		writeDestinationError(c, err)
+		return
 	}
+	req.URL = normalized
 
 	// Get userID from context (set by AuthRequired middleware)
 	userID, err := GetUserIDFromContext(c)
@@ -86,21 +102,192 @@ func (h *Handler) ShortenURL(c *gin.Context) {
 		return
 	}
 
-	// Use controller to create shortened URL
-	urlRecord, err := h.urlController.GenerateShortCode(req.URL, userID)
+	// Validate custom alias up front so callers get a precise message
+	req.Alias = strings.TrimSpace(req.Alias)
+	if req.Alias != "" {
+		if err := util.ValidateAlias(req.Alias); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Resolve the requested expiry against the server's lifetime limits
+	expiresAt, err := config.LinkExpiryPolicy.Resolve(util.ExpiryRequest{
+		ExpiresAt:    req.ExpiresAt,
+		TTL:          req.TTL,
+		NeverExpires: req.NeverExpires,
+	}, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A retried request with the same Idempotency-Key gets the link the first attempt created
+	idempotencyKey := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if idempotencyKey != "" {
+		if len(idempotencyKey) > controller.MaxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Idempotency-Key must be at most %d characters", controller.MaxIdempotencyKeyLength)})
+			return
+		}
+
+		previous, err := h.urlController.ClaimIdempotencyKey(userID, idempotencyKey, requestFingerprint(req))
+		if err != nil {
+			switch err.Error() {
+			case "idempotency key was used with a different request":
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+			case "idempotency key is in use by a request in progress":
+				c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+			default:
+				log.Printf("event=shorten_error user_id=%s reason=idempotency_claim err=%v", userID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shortened URL"})
+			}
+			return
+		}
+		if previous != nil {
+			c.Header("Idempotent-Replayed", "true")
+			c.JSON(http.StatusOK, shortenResponse(previous, false))
+			return
+		}
+	}
+
+	urlRecord, reused, err := h.createOrReuseURL(req, userID, expiresAt)
+	if idempotencyKey != "" {
+		if err != nil {
+			if releaseErr := h.urlController.ReleaseIdempotencyKey(userID, idempotencyKey); releaseErr != nil {
+				log.Printf("event=shorten_error user_id=%s reason=idempotency_release err=%v", userID, releaseErr)
+			}
+		} else if completeErr := h.urlController.CompleteIdempotencyKey(userID, idempotencyKey, urlRecord.ID); completeErr != nil {
+			log.Printf("event=shorten_error user_id=%s reason=idempotency_complete err=%v", userID, completeErr)
+		}
+	}
 	if err != nil {
+		if err.Error() == "folder not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+			return
+		}
+		if err.Error() == "alias already in use" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Alias is already in use"})
+			return
+		}
+		if destinationErrorCode(err) != "" {
+			log.Printf("event=shorten_rejected user_id=%s reason=blocklisted", userID)
+			writeDestinationError(c, err)
+			return
+		}
+		log.Printf("event=shorten_error user_id=%s err=%v", userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shortened URL"})
 		return
 	}
 
-	// Return the shortened URL
-	shortened := "https://www.sniply.co.in/" + urlRecord.ShortCode
+	c.JSON(http.StatusOK, shortenResponse(urlRecord, reused))
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"shortened_url": shortened,
-		"original_url":  req.URL,
-		"short_code":    urlRecord.ShortCode,
-	})
+// createOrReuseURL returns the caller's existing active link to the same destination when reuse
+// applies, and otherwise creates a new one; reuse never applies to custom aliases or passwords
+func (h *Handler) createOrReuseURL(req models.ShortenURLRequest, userID uuid.UUID, expiresAt *time.Time) (*models.URL, bool, error) {
+	if req.Alias == "" && req.Password == "" {
+		reuse := false
+		if req.ReuseExisting != nil {
+			reuse = *req.ReuseExisting
+		} else {
+			settings, err := h.urlController.GetUserSettings(userID)
+			if err != nil {
+				return nil, false, err
+			}
+			reuse = settings.ReuseExistingLinks
+		}
+
+		if reuse {
+			// Only options the request sets have to match the existing link; a ttl resolves to a
+			// new expiry time, so it never matches
+			match := controller.ReuseMatch{
+				NeverExpires: req.NeverExpires,
+				MaxClicks:    req.MaxClicks,
+				Title:        strings.TrimSpace(req.Title),
+				FolderID:     req.FolderID,
+			}
+			if req.RedirectStatus != nil {
+				match.RedirectStatus = *req.RedirectStatus
+			}
+			if req.ExpiresAt != nil || req.TTL != "" {
+				match.ExpiresAt = expiresAt
+			}
+			existing, err := h.urlController.FindReusableURL(userID, req.URL, match)
+			if err != nil {
+				return nil, false, err
+			}
+			if existing != nil {
+				return existing, true, nil
+			}
+		}
+	}
+
+	// Use controller to create shortened URL
+	opts := controller.ShortenOptions{
+		Alias:     req.Alias,
+		ExpiresAt: expiresAt,
+		MaxClicks: req.MaxClicks,
+		Password:  req.Password,
+		Title:     strings.TrimSpace(req.Title),
+		FolderID:  req.FolderID,
+	}
+	if req.RedirectStatus != nil {
+		opts.RedirectStatus = *req.RedirectStatus
+	}
+	urlRecord, err := h.urlController.GenerateShortCode(req.URL, userID, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return urlRecord, false, nil
+}
+
+// writeDestinationError responds 400 to a rejected destination URL, including the validation code
+func writeDestinationError(c *gin.Context, err error) {
+	var validation *util.URLValidationError
+	if errors.As(err, &validation) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": validation.Message, "code": validation.Code, "field": "url"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// destinationErrorCode returns the validation code of a rejected destination URL, or an empty string
+func destinationErrorCode(err error) string {
+	var validation *util.URLValidationError
+	if errors.As(err, &validation) {
+		return validation.Code
+	}
+	return ""
+}
+
+// requestFingerprint identifies the content of a shortening request for Idempotency-Key reuse checks
+// The password is replaced by its HMAC under JWT_SECRET so the stored fingerprint cannot be
+// used to recover or brute-force it offline
+func requestFingerprint(req models.ShortenURLRequest) string {
+	if req.Password != "" {
+		mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+		mac.Write([]byte(req.Password))
+		req.Password = hex.EncodeToString(mac.Sum(nil))
+	}
+	payload, _ := json.Marshal(req)
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// shortenResponse builds the response body for a created or reused link
+func shortenResponse(urlRecord *models.URL, reused bool) gin.H {
+	return gin.H{
+		"shortened_url":      "https://www.sniply.co.in/" + urlRecord.ShortCode,
+		"original_url":       urlRecord.OriginalURL,
+		"short_code":         urlRecord.ShortCode,
+		"title":              urlRecord.Title,
+		"folder_id":          urlRecord.FolderID,
+		"expires_at":         urlRecord.ExpiresAt,
+		"max_clicks":         urlRecord.MaxClicks,
+		"password_protected": urlRecord.PasswordHash != "",
+		"redirect_status":    urlRecord.RedirectStatus,
+		"reused":             reused,
+	}
 }
 
 func (h *Handler) ListURLs(c *gin.Context) {
@@ -111,49 +298,180 @@ func (h *Handler) ListURLs(c *gin.Context) {
 		return
 	}
 
-	// Use controller to fetch URLs with statistics
-	summaries, err := h.urlController.ListURLsByUser(userID)
+	query, err := parseListURLsQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Use controller to fetch one page of URLs with statistics
+	page, err := h.urlController.ListURLsByUser(userID, query)
 	if err != nil {
 		log.Printf("event=list_urls_error user_id=%s reason=query_failed err=%v", userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch URLs"})
 		return
 	}
 
-	// Convert controller URLSummary to response format
-	type urlSummary struct {
-		ShortCode          string     `json:"short_code"`
-		OriginalURL        string     `json:"original_url"`
-		ClickCount         int        `json:"click_count"`
-		CreatedAt          time.Time  `json:"created_at"`
-		UpdatedAt          time.Time  `json:"updated_at"`
-		ExpiresAt          *time.Time `json:"expires_at"`
-		TotalVisits        int64      `json:"total_visits"`
-		UniqueVisitors     int64      `json:"unique_visitors"`
-		LastVisitAt        *time.Time `json:"last_visit_at"`
-		LastVisitUserAgent *string    `json:"last_visit_user_agent"`
+	var nextCursor *string
+	if page.NextCursor != "" {
+		nextCursor = &page.NextCursor
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "OK",
+		"data":        urlSummariesResponse(page.URLs),
+		"next_cursor": nextCursor,
+		"total":       page.Total,
+	})
+}
+
+// parseListURLsQuery reads the paging, sort and filter parameters shared by link listings and exports
+func parseListURLsQuery(c *gin.Context) (controller.ListURLsQuery, error) {
+	query := controller.ListURLsQuery{
+		Cursor: c.Query("cursor"),
+		Sort:   c.Query("sort"),
+		Order:  c.Query("order"),
+		Status: c.Query("status"),
+		Domain: c.Query("domain"),
+		Search: c.Query("q"),
 	}
 
+	if limit := c.Query("limit"); limit != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 {
+			return query, fmt.Errorf("limit must be between 1 and %d", controller.MaxURLPageSize)
+		}
+	}
+
+	if tag := c.Query("tag"); tag != "" {
+		tagID, err := strconv.ParseUint(tag, 10, 64)
+		if err != nil || tagID == 0 {
+			return query, errors.New("tag must be a tag ID")
+		}
+		id := uint(tagID)
+		query.TagID = &id
+	}
+
+	if folder := c.Query("folder"); folder != "" {
+		// "none" lists links that are not in any folder
+		var id uint
+		if folder != "none" {
+			folderID, err := strconv.ParseUint(folder, 10, 64)
+			if err != nil || folderID == 0 {
+				return query, errors.New("folder must be a folder ID or none")
+			}
+			id = uint(folderID)
+		}
+		query.FolderID = &id
+	}
+
+	if from := c.Query("created_from"); from != "" {
+		createdFrom, err := parseRangeBound(from, time.UTC)
+		if err != nil {
+			return query, err
+		}
+		query.CreatedFrom = &createdFrom
+	}
+
+	if to := c.Query("created_to"); to != "" {
+		createdTo, err := parseRangeBound(to, time.UTC)
+		if err != nil {
+			return query, err
+		}
+		query.CreatedTo = &createdTo
+	}
+
+	return query, query.Validate()
+}
+
+// SearchURLs finds the caller's links by short code, destination URL or title
+func (h *Handler) SearchURLs(c *gin.Context) {
+	// Get userID from context (set by AuthRequired middleware)
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	limit := controller.DefaultSearchLimit
+	if value := c.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > controller.MaxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", controller.MaxSearchLimit)})
+			return
+		}
+	}
+
+	results, err := h.urlController.SearchURLs(userID, text, limit)
+	if err != nil {
+		log.Printf("event=search_urls_error user_id=%s err=%v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search URLs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "OK",
+		"data":    urlSummariesResponse(results),
+	})
+}
+
+// urlSummary is the JSON form of a controller URLSummary
+type urlSummary struct {
+	ShortCode          string     `json:"short_code"`
+	OriginalURL        string     `json:"original_url"`
+	Title              string     `json:"title"`
+	FolderID           *uint      `json:"folder_id"`
+	Tags               []gin.H    `json:"tags"`
+	ClickCount         int        `json:"click_count"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	ExpiresAt          *time.Time `json:"expires_at"`
+	MaxClicks          *int       `json:"max_clicks"`
+	PasswordProtected  bool       `json:"password_protected"`
+	RedirectStatus     int        `json:"redirect_status"`
+	BlockedReason      string     `json:"blocked_reason,omitempty"`
+	TotalVisits        int64      `json:"total_visits"`
+	BotVisits          int64      `json:"bot_visits"`
+	UniqueVisitors     int64      `json:"unique_visitors"`
+	DailyUniques       int64      `json:"daily_unique_visitors"`
+	LastVisitAt        *time.Time `json:"last_visit_at"`
+	LastVisitUserAgent *string    `json:"last_visit_user_agent"`
+}
+
+// urlSummariesResponse converts controller URLSummary values to response format
+func urlSummariesResponse(summaries []controller.URLSummary) []urlSummary {
 	response := make([]urlSummary, 0, len(summaries))
 	for _, summary := range summaries {
 		response = append(response, urlSummary{
 			ShortCode:          summary.ShortCode,
 			OriginalURL:        summary.OriginalURL,
+			Title:              summary.Title,
+			FolderID:           summary.FolderID,
+			Tags:               tagsResponse(summary.Tags),
 			ClickCount:         summary.ClickCount,
 			CreatedAt:          summary.CreatedAt,
 			UpdatedAt:          summary.UpdatedAt,
 			ExpiresAt:          summary.ExpiresAt,
+			MaxClicks:          summary.MaxClicks,
+			PasswordProtected:  summary.PasswordProtected,
+			RedirectStatus:     summary.RedirectStatus,
+			BlockedReason:      summary.BlockedReason,
 			TotalVisits:        summary.TotalVisits,
+			BotVisits:          summary.BotVisits,
 			UniqueVisitors:     summary.UniqueVisitors,
+			DailyUniques:       summary.DailyUniques,
 			LastVisitAt:        summary.LastVisitAt,
 			LastVisitUserAgent: summary.LastVisitUserAgent,
 		})
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "OK",
-		"data":    response,
-	})
+	return response
 }
 
 func (h *Handler) DeleteURL(c *gin.Context) {
@@ -184,43 +502,228 @@ func (h *Handler) DeleteURL(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "URL deleted successfully"})
 }
 
+func (h *Handler) UpdateURL(c *gin.Context) {
+	code := c.Param("code")
+
+	var req models.UpdateURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get userID from context (set by AuthRequired middleware)
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.URL != nil {
+		// Apply the same normalisation and checks used when shortening
+		normalized, err := config.DestinationPolicy.Normalize(*req.URL)
+		if err != nil {
+			writeDestinationError(c, err)
+			return
+		}
+		req.URL = &normalized
+	}
+
+	if req.Password != nil && *req.Password != "" && len(*req.Password) < 4 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password must be at least 4 characters"})
+		return
+	}
+	if req.Password != nil {
+		if err := validateLinkPassword(*req.Password); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		req.Title = &title
+	}
+
+	update := controller.URLUpdate{OriginalURL: req.URL, MaxClicks: req.MaxClicks, Password: req.Password, Title: req.Title, FolderID: req.FolderID, RedirectStatus: req.RedirectStatus}
+
+	// Only touch the expiry when the caller asked to change it
+	expiryReq := util.ExpiryRequest{
+		ExpiresAt:    req.ExpiresAt,
+		TTL:          req.TTL,
+		NeverExpires: req.NeverExpires,
+	}
+	if !expiryReq.IsEmpty() {
+		expiresAt, err := config.LinkExpiryPolicy.Resolve(expiryReq, time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		update.ExpiresAt = expiresAt
+		update.ClearExpiry = expiresAt == nil
+	}
+
+	// Use controller to update URL (includes ownership check)
+	urlRecord, err := h.urlController.UpdateURL(code, userID, update)
+	if err != nil {
+		if err.Error() == "URL not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+			return
+		}
+		if err.Error() == "permission denied" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to update this URL"})
+			return
+		}
+		if err.Error() == "folder not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+			return
+		}
+		if destinationErrorCode(err) != "" {
+			log.Printf("event=update_url_rejected code=%s reason=blocklisted", code)
+			writeDestinationError(c, err)
+			return
+		}
+		log.Printf("event=update_url_error code=%s err=%v", code, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update URL"})
+		return
+	}
+
+	log.Printf("event=update_url_success code=%s user_id=%s", code, userID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "URL updated successfully",
+		"data": gin.H{
+			"short_code":         urlRecord.ShortCode,
+			"original_url":       urlRecord.OriginalURL,
+			"title":              urlRecord.Title,
+			"folder_id":          urlRecord.FolderID,
+			"created_at":         urlRecord.CreatedAt,
+			"updated_at":         urlRecord.UpdatedAt,
+			"expires_at":         urlRecord.ExpiresAt,
+			"max_clicks":         urlRecord.MaxClicks,
+			"password_protected": urlRecord.PasswordHash != "",
+			"redirect_status":    urlRecord.RedirectStatus,
+			"blocked_reason":     urlRecord.BlockedReason,
+		},
+	})
+}
+
 func (h *Handler) RedirectURL(c *gin.Context) {
 	code := c.Param("code")
 
+	urlRecord, ok := h.resolveRedirect(c, code)
+	if !ok {
+		return
+	}
+
+	// Password-protected links must be unlocked via POST /:code before redirecting
+	if urlRecord.PasswordHash != "" {
+		log.Printf("event=redirect_locked code=%s", code)
+		h.renderUnlockChallenge(c, code, http.StatusOK, "")
+		return
+	}
+
+	if !h.recordVisit(c, code, urlRecord) {
+		return
+	}
+
+	status := urlRecord.RedirectStatus
+	if status == 0 {
+		status = http.StatusFound
+	}
+	c.Header("Cache-Control", redirectCacheControl(urlRecord, status, time.Now()))
+
+	log.Printf("event=redirect_success code=%s status=%d url=%s", code, status, urlRecord.OriginalURL)
+	c.Redirect(status, urlRecord.OriginalURL)
+}
+
+// redirectCacheControl returns the Cache-Control header for a redirect
+// Temporary redirects are never cached so every click reaches us and is counted. Permanent ones
+// may be cached by the visitor's browser, but not by shared proxies, for at most
+// config.PermanentRedirectMaxAge and never beyond the link's expiry, so browsers come back and
+// clicks keep being tracked; links with a click limit are never cached
+func redirectCacheControl(urlRecord *models.URL, status int, now time.Time) string {
+	const noStore = "no-store, max-age=0"
+	if (status != http.StatusMovedPermanently && status != http.StatusPermanentRedirect) || urlRecord.MaxClicks != nil {
+		return noStore
+	}
+
+	maxAge := config.PermanentRedirectMaxAge
+	if urlRecord.ExpiresAt != nil && urlRecord.ExpiresAt.Sub(now) < maxAge {
+		maxAge = urlRecord.ExpiresAt.Sub(now)
+	}
+	if maxAge < time.Second {
+		return noStore
+	}
+	return fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds()))
+}
+
+// resolveRedirect looks up a short code and checks that it can still be followed
+// On failure it writes the error response and returns false
+func (h *Handler) resolveRedirect(c *gin.Context, code string) (*models.URL, bool) {
 	if strings.TrimSpace(code) == "" {
 		log.Printf("event=redirect_error reason=missing_code")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing short code"})
-		return
+		return nil, false
 	}
 
 	// Use controller to get URL by code
-	urlRecord, err := h.urlController.GetURLByCode(code)
+	// Cached lookup; hot and unknown codes skip the database
+	urlRecord, err := h.urlController.ResolveShortCode(code)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("event=redirect_error code=%s reason=not_found", code)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
-			return
+			return nil, false
 		}
 		log.Printf("event=redirect_error code=%s err=%v", code, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve short URL"})
-		return
+		return nil, false
 	}
 
 	if urlRecord.ExpiresAt != nil && urlRecord.ExpiresAt.Before(time.Now()) {
 		log.Printf("event=redirect_error code=%s reason=expired", code)
 		c.JSON(http.StatusGone, gin.H{"error": "Short URL has expired"})
-		return
+		return nil, false
+	}
+
+	if urlRecord.MaxClicks != nil && urlRecord.ClickCount >= *urlRecord.MaxClicks {
+		log.Printf("event=redirect_error code=%s reason=click_limit_reached", code)
+		c.JSON(http.StatusGone, gin.H{"error": "Short URL has reached its click limit"})
+		return nil, false
+	}
+
+	// Destinations are re-checked so links blocklisted after creation stop redirecting
+	blocked, err := h.urlController.IsBlocked(urlRecord)
+	if err != nil {
+		log.Printf("event=redirect_error code=%s reason=blocklist_update_failed err=%v", code, err)
+	}
+	if blocked {
+		log.Printf("event=redirect_error code=%s reason=blocklisted match=%q", code, urlRecord.BlockedReason)
+		c.JSON(http.StatusForbidden, gin.H{"error": "This link has been disabled because its destination was flagged as unsafe"})
+		return nil, false
 	}
 
-	// Use atomic method to record visit and increment click count together
-	// This ensures both operations succeed or fail together, preventing data inconsistency
-	if err := h.urlController.RecordVisitAndIncrement(urlRecord.ID, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+	return urlRecord, true
+}
+
+// recordVisit stores the visit for a redirect that is about to be issued
+// Returns false (after writing a 410) only when the click limit was hit concurrently
+func (h *Handler) recordVisit(c *gin.Context, code string, urlRecord *models.URL) bool {
+	// Visits are queued for batched writes; links with a click limit are recorded atomically
+	// with their click count increment so the limit can never be exceeded
+	if err := h.urlController.RecordRedirect(urlRecord, c.ClientIP(), c.GetHeader("User-Agent"), c.GetHeader("Referer")); err != nil {
+		if err.Error() == "click limit reached" {
+			// Another request consumed the last allowed click after our lookup, or the cached
+			// click count was stale; refresh the cache so later requests see the limit
+			h.urlController.InvalidateCodes(code)
+			log.Printf("event=redirect_error code=%s reason=click_limit_reached", code)
+			c.JSON(http.StatusGone, gin.H{"error": "Short URL has reached its click limit"})
+			return false
+		}
 		log.Printf("event=redirect_error code=%s reason=visit_record_failed err=%v", code, err)
 		// Continue with redirect even if recording fails - don't block user experience
 	}
-
-	log.Printf("event=redirect_success code=%s url=%s", code, urlRecord.OriginalURL)
-	c.Redirect(http.StatusFound, urlRecord.OriginalURL)
+	return true
 }
 
 func (h *Handler) GetURLStats(c *gin.Context) {
@@ -255,8 +758,16 @@ func (h *Handler) GetURLStats(c *gin.Context) {
 		"click_count":           stats.ClickCount,
 		"total_visits":          stats.TotalVisits,
 		"unique_visitors":       stats.UniqueVisitors,
+		"daily_unique_visitors": stats.DailyUniques,
 		"last_visit_at":         stats.LastVisitAt,
 		"last_visit_user_agent": stats.LastVisitUserAgent,
+		"top_referrers":         breakdownResponse(stats.TopReferrers, "domain"),
+		"browsers":              breakdownResponse(stats.Browsers, "browser"),
+		"operating_systems":     breakdownResponse(stats.OperatingSystems, "os"),
+		"device_types":          breakdownResponse(stats.DeviceTypes, "device_type"),
+		"countries":             breakdownResponse(stats.Countries, "country"),
+		"cities":                breakdownResponse(stats.Cities, "city"),
+		"bot_visits":            stats.BotVisits,
 	})
 }
 
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Debsnil24/URL_Shortner.git/config"
+	"github.com/Debsnil24/URL_Shortner.git/controller"
+	"github.com/Debsnil24/URL_Shortner.git/util"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportFileSize caps the size of an uploaded import CSV
+const maxImportFileSize = 10 << 20
+
+// maxImportTitleLength matches the title limit of ShortenURLRequest; longer legacy titles are truncated
+const maxImportTitleLength = 200
+
+// importRowResult is the JSON form of one import row's outcome
+type importRowResult struct {
+	Line          int    `json:"line"`
+	Status        string `json:"status"` // imported or failed
+	Error         string `json:"error,omitempty"`
+	ErrorCode     string `json:"error_code,omitempty"` // Set when the destination URL was rejected
+	OriginalURL   string `json:"original_url"`
+	RequestedCode string `json:"requested_code,omitempty"`
+	ShortCode     string `json:"short_code,omitempty"`
+	ShortenedURL  string `json:"shortened_url,omitempty"`
+	CodeChanged   bool   `json:"code_changed"` // The requested code was unusable or taken and a new one was assigned
+}
+
+// ImportURLs creates links from an uploaded CSV exported by another shortener
+// The file is sent as the "file" field of a multipart form or as a text/csv body;
+// on_conflict=generate assigns a random code when a row's code is unusable or taken
+// instead of failing the row, and expiry=never keeps rows without an expiry date from
+// getting the default link lifetime
+func (h *Handler) ImportURLs(c *gin.Context) {
+	// Get userID from context (set by AuthRequired middleware)
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	onConflict := c.DefaultQuery("on_conflict", "skip")
+	if onConflict != "skip" && onConflict != "generate" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "on_conflict must be skip or generate"})
+		return
+	}
+
+	expiry := c.DefaultQuery("expiry", "default")
+	if expiry != "default" && expiry != "never" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiry must be default or never"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize)
+	file, err := importFile(c)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file must be at most %d MB", maxImportFileSize>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	rows, err := util.ParseLinkCSV(file, controller.MaxImportRows)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results := make([]importRowResult, len(rows))
+	items := make([]controller.BulkShortenItem, 0, len(rows))
+	positions := make([]int, 0, len(rows)) // Row index of each item passed to the controller
+	now := time.Now()
+	for i, row := range rows {
+		results[i] = importRowResult{Line: row.Line, Status: "failed", OriginalURL: row.URL, RequestedCode: row.Code}
+
+		item, err := prepareImportRow(row, expiry == "never", now)
+		if err != nil {
+			results[i].Error = err.Error()
+			results[i].ErrorCode = destinationErrorCode(err)
+			continue
+		}
+		item.FallbackCode = onConflict == "generate"
+		results[i].OriginalURL = item.OriginalURL
+		items = append(items, item)
+		positions = append(positions, i)
+	}
+
+	created, err := h.urlController.GenerateShortCodes(userID, items)
+	if err != nil {
+		log.Printf("event=import_urls_error user_id=%s rows=%d err=%v", userID, len(rows), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import URLs"})
+		return
+	}
+
+	imported := 0
+	for n, outcome := range created {
+		result := &results[positions[n]]
+		if outcome.Err != nil {
+			result.Error = bulkItemError(outcome.Err)
+			result.ErrorCode = destinationErrorCode(outcome.Err)
+			if result.Error == "" {
+				log.Printf("event=import_url_row_error user_id=%s line=%d err=%v", userID, result.Line, outcome.Err)
+				result.Error = "Failed to create shortened URL"
+			}
+			continue
+		}
+
+		result.Status = "imported"
+		result.ShortCode = outcome.URL.ShortCode
+		result.ShortenedURL = "https://www.sniply.co.in/" + outcome.URL.ShortCode
+		result.CodeChanged = result.RequestedCode != "" && result.RequestedCode != outcome.URL.ShortCode
+		imported++
+	}
+
+	log.Printf("event=import_urls_complete user_id=%s rows=%d imported=%d", userID, len(rows), imported)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("%d of %d rows imported", imported, len(rows)),
+		"data": gin.H{
+			"total":    len(rows),
+			"imported": imported,
+			"failed":   len(rows) - imported,
+			"rows":     results,
+		},
+	})
+}
+
+// importFile returns the uploaded CSV from a multipart "file" field or the raw request body
+func importFile(c *gin.Context) (io.ReadCloser, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, err
+			}
+			return nil, errors.New("file is required")
+		}
+		return header.Open()
+	}
+	return c.Request.Body, nil
+}
+
+// prepareImportRow validates one parsed CSV row the same way ShortenURL validates a single request
+// Rows without an expiry date get the default lifetime, or none when neverExpires is set
+func prepareImportRow(row util.ImportedLink, neverExpires bool, now time.Time) (controller.BulkShortenItem, error) {
+	if row.Err != nil {
+		return controller.BulkShortenItem{}, row.Err
+	}
+
+	originalURL, err := config.DestinationPolicy.Normalize(row.URL)
+	if err != nil {
+		return controller.BulkShortenItem{}, err
+	}
+
+	title := row.Title
+	if utf8.RuneCountInString(title) > maxImportTitleLength {
+		title = string([]rune(title)[:maxImportTitleLength])
+	}
+
+	// The file's expiry date is checked against the same bounds as a new link's
+	expiresAt, err := config.LinkExpiryPolicy.Resolve(util.ExpiryRequest{
+		ExpiresAt:    row.ExpiresAt,
+		NeverExpires: row.ExpiresAt == nil && neverExpires,
+	}, now)
+	if err != nil {
+		return controller.BulkShortenItem{}, err
+	}
+
+	return controller.BulkShortenItem{
+		OriginalURL: originalURL,
+		Options: controller.ShortenOptions{
+			Alias:     row.Code,
+			ExpiresAt: expiresAt,
+			Title:     title,
+		},
+		TagNames:   row.Tags,
+		CreatedAt:  row.CreatedAt,
+		ClickCount: row.Clicks,
+	}, nil
+}
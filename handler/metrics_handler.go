@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Debsnil24/URL_Shortner.git/controller"
+	"github.com/gin-gonic/gin"
+)
+
+// VisitMetrics reports the visit recorder's queue depth and counters for monitoring back-pressure
+// A rising overflowed count means redirects are falling back to synchronous writes
+func (h *Handler) VisitMetrics(c *gin.Context) {
+	recorder := controller.ActiveVisitRecorder()
+	if recorder == nil {
+		c.JSON(http.StatusOK, gin.H{"mode": "sync"})
+		return
+	}
+
+	stats := recorder.Stats()
+	c.JSON(http.StatusOK, gin.H{
+		"mode":           "async",
+		"queue_length":   stats.QueueLength,
+		"queue_capacity": stats.QueueCapacity,
+		"workers":        stats.Workers,
+		"enqueued":       stats.Enqueued,
+		"recorded":       stats.Recorded,
+		"overflowed":     stats.Overflowed,
+		"failed":         stats.Failed,
+		"batches":        stats.Batches,
+	})
+}
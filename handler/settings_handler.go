@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Debsnil24/URL_Shortner.git/controller"
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/gin-gonic/gin"
+)
+
+// settingsResponse converts user settings to response format
+func settingsResponse(settings *controller.UserSettings) gin.H {
+	return gin.H{
+		"reuse_existing_links":    settings.ReuseExistingLinks,
+		"default_redirect_status": settings.DefaultRedirectStatus,
+	}
+}
+
+// GetSettings returns the caller's link defaults
+func (h *Handler) GetSettings(c *gin.Context) {
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.urlController.GetUserSettings(userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.Printf("event=get_settings_error user_id=%s err=%v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "OK", "data": settingsResponse(settings)})
+}
+
+// UpdateSettings changes the caller's link defaults
+func (h *Handler) UpdateSettings(c *gin.Context) {
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.UpdateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.urlController.UpdateUserSettings(userID, controller.UserSettingsUpdate{
+		ReuseExistingLinks:    req.ReuseExistingLinks,
+		DefaultRedirectStatus: req.DefaultRedirectStatus,
+	})
+	if err != nil {
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.Printf("event=update_settings_error user_id=%s err=%v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Settings updated successfully", "data": settingsResponse(settings)})
+}
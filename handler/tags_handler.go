@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Debsnil24/URL_Shortner.git/controller"
+	"github.com/Debsnil24/URL_Shortner.git/models"
+	"github.com/gin-gonic/gin"
+)
+
+// tagsResponse converts a link's tags to response format
+func tagsResponse(tags []controller.TagRef) []gin.H {
+	response := make([]gin.H, 0, len(tags))
+	for _, tag := range tags {
+		response = append(response, gin.H{"id": tag.ID, "name": tag.Name})
+	}
+	return response
+}
+
+// labelsResponse converts tag or folder summaries to response format
+func labelsResponse(labels []controller.LabelSummary) []gin.H {
+	response := make([]gin.H, 0, len(labels))
+	for _, label := range labels {
+		response = append(response, gin.H{
+			"id":           label.ID,
+			"name":         label.Name,
+			"created_at":   label.CreatedAt,
+			"link_count":   label.LinkCount,
+			"total_clicks": label.TotalClicks,
+		})
+	}
+	return response
+}
+
+// parseLabelID reads the numeric :id route parameter, writing a 400 when it is invalid
+func parseLabelID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// writeLabelError maps tag and folder controller errors to responses
+func writeLabelError(c *gin.Context, event string, err error) {
+	message := err.Error()
+	switch {
+	case message == "tag not found", message == "folder not found", message == "URL not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": strings.ToUpper(message[:1]) + message[1:]})
+	case message == "tag already exists", message == "folder already exists":
+		c.JSON(http.StatusConflict, gin.H{"error": strings.ToUpper(message[:1]) + message[1:]})
+	case message == "permission denied":
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to update this URL"})
+	case strings.HasPrefix(message, "name "):
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+	default:
+		log.Printf("event=%s err=%v", event, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Request failed"})
+	}
+}
+
+// ListTags returns the caller's tags with link counts and total clicks
+func (h *Handler) ListTags(c *gin.Context) {
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	tags, err := h.urlController.ListTags(userID)
+	if err != nil {
+		writeLabelError(c, "list_tags_error", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "OK", "data": labelsResponse(tags)})
+}
+
+// CreateTag adds a tag for the caller
+func (h *Handler) CreateTag(c *gin.Context) {
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := h.urlController.CreateTag(userID, req.Name)
+	if err != nil {
+		writeLabelError(c, "create_tag_error", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Tag created successfully",
+		"data":    gin.H{"id": tag.ID, "name": tag.Name, "created_at": tag.CreatedAt},
+	})
+}
+
+// RenameTag changes the name of one of the caller's tags
+func (h *Handler) RenameTag(c *gin.Context) {
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	tagID, ok := parseLabelID(c)
+	if !ok {
+		return
+	}
+
+	var req models.LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := h.urlController.RenameTag(userID, tagID, req.Name)
+	if err != nil {
+		writeLabelError(c, "rename_tag_error", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Tag updated successfully",
+		"data":    gin.H{"id": tag.ID, "name": tag.Name, "created_at": tag.CreatedAt},
+	})
+}
+
+// DeleteTag deletes one of the caller's tags and removes it from every link
+func (h *Handler) DeleteTag(c *gin.Context) {
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	tagID, ok := parseLabelID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.urlController.DeleteTag(userID, tagID); err != nil {
+		writeLabelError(c, "delete_tag_error", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
+
+// SetURLTags replaces the tags on one of the caller's links
+func (h *Handler) SetURLTags(c *gin.Context) {
+	code := c.Param("code")
+
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.SetURLTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tags, err := h.urlController.SetURLTags(code, userID, req.TagIDs)
+	if err != nil {
+		writeLabelError(c, "set_url_tags_error", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Tags updated successfully",
+		"data":    gin.H{"short_code": code, "tags": tagsResponse(tags)},
+	})
+}
+
+// ListFolders returns the caller's folders with link counts and total clicks
+func (h *Handler) ListFolders(c *gin.Context) {
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	folders, err := h.urlController.ListFolders(userID)
+	if err != nil {
+		writeLabelError(c, "list_folders_error", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "OK", "data": labelsResponse(folders)})
+}
+
+// CreateFolder adds a folder for the caller
+func (h *Handler) CreateFolder(c *gin.Context) {
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder, err := h.urlController.CreateFolder(userID, req.Name)
+	if err != nil {
+		writeLabelError(c, "create_folder_error", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Folder created successfully",
+		"data":    gin.H{"id": folder.ID, "name": folder.Name, "created_at": folder.CreatedAt},
+	})
+}
+
+// RenameFolder changes the name of one of the caller's folders
+func (h *Handler) RenameFolder(c *gin.Context) {
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	folderID, ok := parseLabelID(c)
+	if !ok {
+		return
+	}
+
+	var req models.LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder, err := h.urlController.RenameFolder(userID, folderID, req.Name)
+	if err != nil {
+		writeLabelError(c, "rename_folder_error", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Folder updated successfully",
+		"data":    gin.H{"id": folder.ID, "name": folder.Name, "created_at": folder.CreatedAt},
+	})
+}
+
+// DeleteFolder deletes one of the caller's folders; its links become unfiled
+func (h *Handler) DeleteFolder(c *gin.Context) {
+	userID, err := GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	folderID, ok := parseLabelID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.urlController.DeleteFolder(userID, folderID); err != nil {
+		writeLabelError(c, "delete_folder_error", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Folder deleted successfully"})
+}
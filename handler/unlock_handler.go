@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// Brute-force throttling for password-protected links
+// Each visitor gets 5 attempts per 15 minutes per code. Guessers spread across many addresses
+// are slowed per code instead: once a code has failed more than unlockFreeFailures times, every
+// attempt on it waits an escalating delay before the password is checked. A delay rather than a
+// hard per-code limit means nobody can lock legitimate visitors out of the link
+var (
+	unlockVisitorLimiter = middleware.NewRateLimiter(5, 15*time.Minute)
+	unlockCodeFailures   = newUnlockFailures(15 * time.Minute)
+)
+
+// Per-code unlock delay
+const (
+	unlockFreeFailures = 10                     // Failures in the window before attempts are delayed
+	unlockBaseDelay    = 250 * time.Millisecond // Delay after the first failure beyond the free ones
+	unlockMaxDelay     = 10 * time.Second
+)
+
+// unlockFailure counts a code's recent failed unlock attempts
+type unlockFailure struct {
+	count int
+	last  time.Time
+}
+
+// unlockFailures tracks failed unlock attempts per code; a code's count is forgotten once it has
+// seen no failure for the window, and reset by a successful unlock
+type unlockFailures struct {
+	window time.Duration
+
+	mu    sync.Mutex
+	codes map[string]*unlockFailure
+}
+
+func newUnlockFailures(window time.Duration) *unlockFailures {
+	return &unlockFailures{window: window, codes: make(map[string]*unlockFailure)}
+}
+
+// Delay returns how long an attempt on code should wait before its password is checked
+// It doubles with every failure beyond unlockFreeFailures, up to unlockMaxDelay
+func (f *unlockFailures) Delay(code string) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	failure, ok := f.codes[code]
+	if !ok || time.Since(failure.last) > f.window {
+		return 0
+	}
+	excess := failure.count - unlockFreeFailures
+	if excess <= 0 {
+		return 0
+	}
+	delay := unlockBaseDelay
+	for i := 1; i < excess && delay < unlockMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > unlockMaxDelay {
+		delay = unlockMaxDelay
+	}
+	return delay
+}
+
+// Fail records a wrong password for code
+func (f *unlockFailures) Fail(code string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	failure, ok := f.codes[code]
+	if !ok || now.Sub(failure.last) > f.window {
+		failure = &unlockFailure{}
+		f.codes[code] = failure
+	}
+	failure.count++
+	failure.last = now
+
+	// Drop codes that have been quiet for the window so the map stays small
+	if len(f.codes) > 1000 {
+		for other, entry := range f.codes {
+			if now.Sub(entry.last) > f.window {
+				delete(f.codes, other)
+			}
+		}
+	}
+}
+
+// Reset forgets the failures of code after a successful unlock
+func (f *unlockFailures) Reset(code string) {
+	f.mu.Lock()
+	delete(f.codes, code)
+	f.mu.Unlock()
+}
+
+// maxPasswordBytes is bcrypt's input limit; longer passwords make hashing fail
+const maxPasswordBytes = 72
+
+// validateLinkPassword checks a new link password against bcrypt's byte limit,
+// which multibyte characters reach sooner than a character count suggests
+func validateLinkPassword(password string) error {
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordBytes)
+	}
+	return nil
+}
+
+type unlockRequest struct {
+	Password string `form:"password" json:"password"`
+}
+
+// UnlockURL verifies the password of a protected short link and then redirects to it
+func (h *Handler) UnlockURL(c *gin.Context) {
+	code := c.Param("code")
+
+	if !unlockVisitorLimiter.Allow(code + "|" + c.ClientIP()) {
+		log.Printf("event=unlock_error code=%s ip=%s reason=rate_limited", code, c.ClientIP())
+		h.renderUnlockChallenge(c, code, http.StatusTooManyRequests, "Too many attempts. Please try again later.")
+		return
+	}
+
+	urlRecord, ok := h.resolveRedirect(c, code)
+	if !ok {
+		return
+	}
+
+	if urlRecord.PasswordHash != "" {
+		var req unlockRequest
+		if err := c.ShouldBind(&req); err != nil || req.Password == "" {
+			h.renderUnlockChallenge(c, code, http.StatusBadRequest, "Password is required.")
+			return
+		}
+
+		if delay := unlockCodeFailures.Delay(code); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		if !h.urlController.CheckURLPassword(urlRecord, req.Password) {
+			unlockCodeFailures.Fail(code)
+			log.Printf("event=unlock_error code=%s ip=%s reason=wrong_password", code, c.ClientIP())
+			h.renderUnlockChallenge(c, code, http.StatusUnauthorized, "Incorrect password.")
+			return
+		}
+		unlockCodeFailures.Reset(code)
+	}
+
+	if !h.recordVisit(c, code, urlRecord) {
+		return
+	}
+
+	log.Printf("event=unlock_success code=%s url=%s", code, urlRecord.OriginalURL)
+	if wantsJSON(c) {
+		c.JSON(http.StatusOK, gin.H{"success": true, "original_url": urlRecord.OriginalURL})
+		return
+	}
+	// 303 makes the browser follow the destination with a GET after the form POST
+	c.Redirect(http.StatusSeeOther, urlRecord.OriginalURL)
+}
+
+// renderUnlockChallenge asks the visitor for a link's password, as JSON for API clients
+// or as a minimal HTML form for browsers
+func (h *Handler) renderUnlockChallenge(c *gin.Context, code string, status int, message string) {
+	if wantsJSON(c) {
+		if status == http.StatusOK {
+			status = http.StatusUnauthorized
+		}
+		errorMessage := message
+		if errorMessage == "" {
+			errorMessage = "Password required"
+		}
+		c.JSON(status, gin.H{"error": errorMessage, "password_required": true, "unlock_url": "/" + code})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(status, "text/html; charset=utf-8", []byte(generateUnlockPage(code, message)))
+}
+
+// wantsJSON reports whether the client is an API caller rather than a browser
+func wantsJSON(c *gin.Context) bool {
+	return c.ContentType() == gin.MIMEJSON || c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON
+}
+
+// generateUnlockPage renders the password form for a protected link
+func generateUnlockPage(code, message string) string {
+	escapedCode := html.EscapeString(code)
+	errorBlock := ""
+	if message != "" {
+		errorBlock = fmt.Sprintf(`<p style="color: #c0392b; margin: 0 0 15px 0;">%s</p>`, html.EscapeString(message))
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+	<meta name="robots" content="noindex">
+	<title>Protected Link</title>
+</head>
+<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333; max-width: 400px; margin: 80px auto; padding: 20px;">
+	<div style="background-color: #ffffff; padding: 20px; border: 1px solid #ddd; border-radius: 5px;">
+		<h2 style="color: #2c3e50; margin-top: 0;">This link is password protected</h2>
+		%s
+		<form method="POST" action="/%s">
+			<input type="password" name="password" placeholder="Password" autofocus required autocomplete="off"
+				style="width: 100%%; padding: 10px; margin-bottom: 15px; border: 1px solid #ddd; border-radius: 5px; box-sizing: border-box;">
+			<button type="submit" style="width: 100%%; padding: 10px; background-color: #3498db; color: #fff; border: none; border-radius: 5px; cursor: pointer;">Continue</button>
+		</form>
+	</div>
+</body>
+</html>`, errorBlock, escapedCode)
+}
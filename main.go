@@ -1,10 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/Debsnil24/URL_Shortner.git/config"
+	"github.com/Debsnil24/URL_Shortner.git/controller"
 	"github.com/Debsnil24/URL_Shortner.git/routes"
+	"github.com/Debsnil24/URL_Shortner.git/service"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/go-gormigrate/gormigrate/v2"
@@ -17,9 +26,46 @@ func main() {
 		log.Fatalf("❌ Migration Failed: %v", err)
 	}
 
+	// Share the daily IP hash salts between instances through the database
+	service.GetIPAnonymizer().UseSaltStore(controller.NewIPSaltStore(DB))
+
+	// One-off job: `main anonymise-visit-ips` rewrites stored visit IPs using IP_PRIVACY_MODE
+	if len(os.Args) > 1 && os.Args[1] == "anonymise-visit-ips" {
+		rows, skipped, err := controller.NewURLController(DB).AnonymiseStoredIPs()
+		if err != nil {
+			log.Fatalf("❌ Anonymising visit IPs failed: %v", err)
+		}
+		log.Printf("✅ Anonymised %d visit IP addresses", rows)
+		if skipped > 0 {
+			log.Printf("⚠️ Skipped %d visits whose stored IP is not a valid address", skipped)
+		}
+		return
+	}
+
 	// Initialize Google OAuth (reads env vars)
 	config.InitGoogleOAuth()
 
+	// Load link lifetime limits (reads env vars)
+	config.InitLinkPolicy()
+
+	// Roll old visits into daily aggregates in the background (reads env vars)
+	config.InitVisitRetention()
+	if config.VisitRetention > 0 {
+		controller.NewURLController(DB).StartVisitRollup(config.VisitRetention, config.VisitRollupInterval)
+	}
+
+	// Record redirect visits in background batches (reads env vars)
+	config.InitVisitRecording()
+	var visitRecorder *controller.VisitRecorder
+	if config.VisitRecording == "async" {
+		visitRecorder = controller.NewURLController(DB).StartVisitRecorder(controller.VisitRecorderOptions{
+			QueueSize:     config.VisitQueueSize,
+			Workers:       config.VisitWorkers,
+			BatchSize:     config.VisitBatchSize,
+			FlushInterval: config.VisitFlushInterval,
+		})
+	}
+
 	router := gin.Default()
 
 	// Configure CORS
@@ -31,12 +77,36 @@ func main() {
 		"https://sniply.co.in",     // Add without www
 		"https://dev.sniply.co.in", // Add dev environment
 	}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"}
+	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "Idempotency-Key"}
+	config.ExposeHeaders = []string{"Idempotent-Replayed"}
 	config.AllowCredentials = true
 	router.Use(cors.New(config))
 
 	routes.RegisterRoutes(router)
 
-	router.Run(":8080")
+	server := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("❌ Server Failed: %v", err)
+		}
+	}()
+
+	// On SIGINT/SIGTERM stop accepting requests, finish in-flight ones and flush queued visits
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	log.Printf("event=shutdown_started")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("event=shutdown_error component=http err=%v", err)
+	}
+	if visitRecorder != nil {
+		if err := visitRecorder.Close(ctx); err != nil {
+			log.Printf("event=shutdown_error component=visit_recorder err=%v", err)
+		}
+	}
+	log.Printf("event=shutdown_complete")
 }
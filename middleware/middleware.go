@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"net/http"
 	"strings"
@@ -59,6 +60,28 @@ func AuthRequired() gin.HandlerFunc {
 	}
 }
 
+// MetricsTokenRequired guards operational endpoints with a shared bearer token, separate from
+// user sessions so that signing up does not grant access. The route answers 404 when token is empty
+func MetricsTokenRequired(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			c.Abort()
+			return
+		}
+
+		authHeader := c.GetHeader("Authorization")
+		if !strings.HasPrefix(strings.ToLower(authHeader), "bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimSpace(authHeader[len("Bearer "):])), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.JSON(http.StatusUnauthorized, models.AuthResponse{Success: false, Error: &models.AuthError{Code: "AUTH_401", Message: "Missing or invalid metrics token"}})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 // RateLimiter stores request timestamps per IP address
 type RateLimiter struct {
 	requests map[string][]time.Time
@@ -1,7 +1,63 @@
 package models
 
+import "time"
+
 type ShortenURLRequest struct {
-	URL string `json:"url" binding:"required,url"`
+	URL          string     `json:"url" binding:"required"` // Normalised and checked by config.DestinationPolicy
+	Alias        string     `json:"alias"`                  // Optional custom short code
+	ExpiresAt    *time.Time `json:"expires_at"`             // Absolute expiry (RFC 3339)
+	TTL          string     `json:"ttl"`                    // Relative expiry such as "72h" or "30d"
+	NeverExpires bool       `json:"never_expires"`          // Create the link without an expiry
+	MaxClicks    *int       `json:"max_clicks" binding:"omitempty,min=1"`
+	Password     string     `json:"password" binding:"omitempty,min=4"` // Visitors must enter this before being redirected; at most 72 bytes
+	Title        string     `json:"title" binding:"omitempty,max=200"`
+	FolderID     *uint      `json:"folder_id" binding:"omitempty,min=1"`
+	// ReuseExisting returns the caller's active link to an identical destination instead of creating one;
+	// nil falls back to the caller's reuse_existing_links setting
+	ReuseExisting *bool `json:"reuse_existing"`
+	// RedirectStatus chooses a permanent (301, 308) or temporary (302, 307) redirect;
+	// nil falls back to the caller's default_redirect_status setting
+	RedirectStatus *int `json:"redirect_status" binding:"omitempty,oneof=301 302 307 308"`
+}
+
+// BulkShortenRequest creates many links at once; each item is validated on its own
+type BulkShortenRequest struct {
+	URLs []BulkShortenItem `json:"urls" binding:"required,min=1"`
+}
+
+// BulkShortenItem is one link of a bulk request
+type BulkShortenItem struct {
+	ShortenURLRequest
+	TagIDs []uint `json:"tag_ids"`
+}
+
+// UpdateURLRequest contains the mutable fields of a short link; omitted fields are left unchanged
+type UpdateURLRequest struct {
+	URL            *string    `json:"url"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	TTL            string     `json:"ttl"`
+	NeverExpires   bool       `json:"never_expires"`
+	MaxClicks      *int       `json:"max_clicks" binding:"omitempty,min=0"` // 0 removes the limit
+	Password       *string    `json:"password"`                             // Empty string removes the password; at most 72 bytes
+	Title          *string    `json:"title" binding:"omitempty,max=200"`    // Empty string removes the title
+	FolderID       *uint      `json:"folder_id"`                            // 0 moves the link out of its folder
+	RedirectStatus *int       `json:"redirect_status" binding:"omitempty,oneof=301 302 307 308"`
+}
+
+// UpdateSettingsRequest changes the caller's link defaults; omitted fields are left unchanged
+type UpdateSettingsRequest struct {
+	ReuseExistingLinks    *bool `json:"reuse_existing_links"`
+	DefaultRedirectStatus *int  `json:"default_redirect_status" binding:"omitempty,oneof=301 302 307 308"`
+}
+
+// LabelRequest names a tag or folder
+type LabelRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// SetURLTagsRequest replaces the tags on a link; an empty list removes them all
+type SetURLTagsRequest struct {
+	TagIDs []uint `json:"tag_ids" binding:"required"`
 }
 
 type ShortenURLResponse struct {
@@ -20,25 +20,110 @@ type User struct {
 	LastLogin     *time.Time `json:"last_login"`
 	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt     time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+	// ReuseExistingLinks makes shortening return the user's active link to an identical destination by default
+	ReuseExistingLinks bool `json:"reuse_existing_links" gorm:"not null;default:false"`
+	// DefaultRedirectStatus is the HTTP status new links redirect with unless they choose one
+	DefaultRedirectStatus int `json:"default_redirect_status" gorm:"not null;default:302"`
 }
 
 type URL struct {
-	ID          uint      `gorm:"primaryKey"`
-	ShortCode   string    `gorm:"size:10;unique;not null"`
-	OriginalURL string    `gorm:"not null"`
-	UserID      uuid.UUID `gorm:"type:uuid"`
-	User        User      `gorm:"constraint:OnDelete:CASCADE;"`
-	CreatedAt   time.Time `gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
-	ExpiresAt   *time.Time
-	ClickCount  int
+	ID           uint      `gorm:"primaryKey"`
+	ShortCode    string    `gorm:"size:10;unique;not null"`
+	OriginalURL  string    `gorm:"not null"`
+	Title        string    // Optional owner-supplied label, searchable alongside the code and URL
+	UserID       uuid.UUID `gorm:"type:uuid"`
+	User         User      `gorm:"constraint:OnDelete:CASCADE;"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+	ExpiresAt    *time.Time
+	ClickCount   int
+	MaxClicks    *int   // Redirects stop once ClickCount reaches this cap; nil means unlimited
+	PasswordHash string // bcrypt hash; empty when the link is not password protected
+	FolderID     *uint  // Folder the link is filed under; nil when unfiled
+	// ImportedClicks is the part of ClickCount carried over from another shortener, which has no visit rows
+	ImportedClicks int `gorm:"not null;default:0"`
+	// DestinationKey is OriginalURL normalised for matching links to the same destination
+	DestinationKey string
+	// BlockedReason names the blocklist entry matching the destination; redirects are refused while it is set
+	BlockedReason string
+	BlockedAt     *time.Time
+	// RedirectStatus is the HTTP status visitors are redirected with: 301, 302, 307 or 308
+	RedirectStatus int `gorm:"not null;default:302"`
 }
 
 type URLVisit struct {
-	ID        uint `gorm:"primaryKey"`
-	URLID     uint
-	URL       URL `gorm:"constraint:OnDelete:CASCADE;"`
-	IPAddress string
-	UserAgent string
+	ID             uint `gorm:"primaryKey"`
+	URLID          uint
+	URL            URL `gorm:"constraint:OnDelete:CASCADE;"`
+	IPAddress      string
+	UserAgent      string
+	Referrer       string    // Full Referer URL, empty for direct traffic
+	ReferrerDomain string    `gorm:"size:255"` // Normalised referring host without "www."
+	Browser        string    `gorm:"size:50"`  // Parsed from UserAgent at visit time
+	OS             string    `gorm:"size:50"`
+	DeviceType     string    `gorm:"size:20"` // desktop, mobile, tablet or bot
+	IsBot          bool      `gorm:"default:false"`
+	Country        string    `gorm:"size:2"` // ISO country code from the GeoIP database; empty when unknown
+	Region         string    `gorm:"size:100"`
+	City           string    `gorm:"size:100"`
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
+
+// URLDailyStat holds one UTC day of visits for a URL after its raw url_visits rows were rolled up
+type URLDailyStat struct {
+	ID             uint      `gorm:"primaryKey"`
+	URLID          uint      `gorm:"uniqueIndex:idx_url_daily_stats_url_day"`
+	URL            URL       `gorm:"constraint:OnDelete:CASCADE;"`
+	Day            time.Time `gorm:"type:date;uniqueIndex:idx_url_daily_stats_url_day"`
+	TotalVisits    int64     // All visits, including bots
+	Clicks         int64     // Human visits only
+	BotVisits      int64
+	UniqueVisitors int64 // Distinct human IPs within the day
+	LastVisitAt    time.Time
+	LastUserAgent  string
+}
+
+// URLDailyBreakdown holds one of the top values of a visit breakdown column for a rolled-up day
+type URLDailyBreakdown struct {
+	ID        uint      `gorm:"primaryKey"`
+	URLID     uint      `gorm:"uniqueIndex:idx_url_daily_breakdowns_key"`
+	URL       URL       `gorm:"constraint:OnDelete:CASCADE;"`
+	Day       time.Time `gorm:"type:date;uniqueIndex:idx_url_daily_breakdowns_key"`
+	Dimension string    `gorm:"size:20;uniqueIndex:idx_url_daily_breakdowns_key"` // url_visits column, e.g. referrer_domain
+	Value     string    `gorm:"uniqueIndex:idx_url_daily_breakdowns_key"`         // Empty when the column was empty
+	Visits    int64
+}
+
+// Tag is a user-defined label; a link can carry any number of its owner's tags
+type Tag struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE;"`
+	Name      string    `gorm:"size:50;not null"` // Unique per user, ignoring case
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 }
+
+// URLTag links a URL to one of its tags
+type URLTag struct {
+	URLID uint `gorm:"primaryKey"`
+	TagID uint `gorm:"primaryKey"`
+}
+
+// Folder groups a user's links; a link belongs to at most one folder
+type Folder struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE;"`
+	Name      string    `gorm:"size:100;not null"` // Unique per user, ignoring case
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// IdempotencyKey remembers the link created for a client-supplied Idempotency-Key so retries return it
+type IdempotencyKey struct {
+	ID          uint      `gorm:"primaryKey"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null"`
+	Key         string    `gorm:"size:255;not null"` // Unique per user
+	Fingerprint string    `gorm:"size:64;not null"`  // SHA-256 of the request it was first used with
+	URLID       *uint     // Link created for the key; nil while the first request is still running
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
@@ -14,13 +14,34 @@ func RegisterRoutes(router *gin.Engine) {
 
 	// Public redirect route
 	router.GET("/:code", h.RedirectURL)
+	// Password-protected links are unlocked by posting the password to the same path
+	router.POST("/:code", h.UnlockURL)
 
 	api := router.Group("/api")
 	{
 		api.GET("/test", h.TestHandler)
+		api.GET("/metrics/visits", middleware.MetricsTokenRequired(config.VisitMetricsToken), h.VisitMetrics)
 		api.POST("/shorten", middleware.AuthRequired(), h.ShortenURL)
+		api.POST("/shorten/bulk", middleware.AuthRequired(), h.ShortenURLsBulk)
 		api.GET("/urls", middleware.AuthRequired(), h.ListURLs)
+		api.GET("/urls/search", middleware.AuthRequired(), h.SearchURLs)
+		api.POST("/urls/import", middleware.AuthRequired(), h.ImportURLs)
+		api.GET("/urls/export", middleware.AuthRequired(), h.ExportURLs)
 		api.GET("/urls/:code/stats", middleware.AuthRequired(), h.GetURLStats)
+		api.GET("/urls/:code/timeseries", middleware.AuthRequired(), h.GetURLTimeSeries)
+		api.GET("/urls/:code/visits/export", middleware.AuthRequired(), h.ExportVisits)
+		api.PATCH("/urls/:code", middleware.AuthRequired(), h.UpdateURL)
+		api.PUT("/urls/:code/tags", middleware.AuthRequired(), h.SetURLTags)
+		api.GET("/tags", middleware.AuthRequired(), h.ListTags)
+		api.POST("/tags", middleware.AuthRequired(), h.CreateTag)
+		api.PATCH("/tags/:id", middleware.AuthRequired(), h.RenameTag)
+		api.DELETE("/tags/:id", middleware.AuthRequired(), h.DeleteTag)
+		api.GET("/folders", middleware.AuthRequired(), h.ListFolders)
+		api.POST("/folders", middleware.AuthRequired(), h.CreateFolder)
+		api.PATCH("/folders/:id", middleware.AuthRequired(), h.RenameFolder)
+		api.DELETE("/folders/:id", middleware.AuthRequired(), h.DeleteFolder)
+		api.GET("/settings", middleware.AuthRequired(), h.GetSettings)
+		api.PATCH("/settings", middleware.AuthRequired(), h.UpdateSettings)
 		api.DELETE("/delete/:code", middleware.AuthRequired(), h.DeleteURL)
 		// Support endpoint with rate limiting and timeout
 		api.POST("/support", middleware.RateLimit(), middleware.RequestTimeout(30*time.Second), h.SubmitSupport)
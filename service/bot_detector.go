@@ -0,0 +1,206 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/util"
+)
+
+// botRule is a single custom classification rule loaded from the rules file
+type botRule struct {
+	name    string
+	token   string         // Lowercase User-Agent substring
+	pattern *regexp.Regexp // User-Agent regular expression
+	network *net.IPNet     // Source address range (e.g. an uptime monitor's probes)
+}
+
+// matches reports whether the rule applies to the request
+func (r botRule) matches(userAgent string, ip net.IP) bool {
+	switch {
+	case r.token != "":
+		return strings.Contains(strings.ToLower(userAgent), r.token)
+	case r.pattern != nil:
+		return r.pattern.MatchString(userAgent)
+	case r.network != nil:
+		return ip != nil && r.network.Contains(ip)
+	}
+	return false
+}
+
+// BotDetector classifies visits using rules from a local file on top of the
+// built-in User-Agent detection, reloading the file whenever it changes
+type BotDetector struct {
+	path    string
+	mu      sync.RWMutex
+	rules   []botRule
+	modTime time.Time
+}
+
+var (
+	botDetectorInstance *BotDetector
+	botDetectorOnce     sync.Once
+)
+
+// GetBotDetector returns a singleton BotDetector configured from BOT_RULES_FILE
+// When the variable is unset only the built-in User-Agent detection applies
+func GetBotDetector() *BotDetector {
+	botDetectorOnce.Do(func() {
+		botDetectorInstance = NewBotDetector(os.Getenv("BOT_RULES_FILE"))
+		if botDetectorInstance.path != "" {
+			go botDetectorInstance.watch(30 * time.Second)
+		}
+	})
+	return botDetectorInstance
+}
+
+// NewBotDetector creates a BotDetector that loads its rules from path (for testing or custom config)
+// For production use, prefer GetBotDetector() singleton
+func NewBotDetector(path string) *BotDetector {
+	d := &BotDetector{path: path}
+	if path != "" {
+		if err := d.Reload(); err != nil {
+			log.Printf("event=bot_rules_error path=%s err=%v", path, err)
+		}
+	}
+	return d
+}
+
+// Match checks the custom rules and returns the matching rule's name
+func (d *BotDetector) Match(userAgent, ipAddress string) (string, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	ip := net.ParseIP(ipAddress)
+	for _, rule := range d.rules {
+		if rule.matches(userAgent, ip) {
+			return rule.name, true
+		}
+	}
+	return "", false
+}
+
+// Reload re-reads the rules file, keeping the previous rules if it cannot be parsed
+func (d *BotDetector) Reload() error {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return err
+	}
+
+	rules, err := loadBotRules(d.path)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.rules = rules
+	d.modTime = info.ModTime()
+	d.mu.Unlock()
+
+	log.Printf("event=bot_rules_loaded path=%s rules=%d", d.path, len(rules))
+	return nil
+}
+
+// watch reloads the rules file whenever its modification time changes
+func (d *BotDetector) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		info, err := os.Stat(d.path)
+		if err != nil {
+			continue
+		}
+
+		d.mu.RLock()
+		changed := !info.ModTime().Equal(d.modTime)
+		d.mu.RUnlock()
+
+		if changed {
+			if err := d.Reload(); err != nil {
+				log.Printf("event=bot_rules_error path=%s err=%v", d.path, err)
+			}
+		}
+	}
+}
+
+// loadBotRules parses a rules file with one rule per line:
+//
+//	ua:<substring>   case-insensitive User-Agent substring (the default when no prefix is given)
+//	re:<regexp>      User-Agent regular expression
+//	ip:<cidr|ip>     source address or range
+//
+// A rule may be followed by " | <name>" to label matching visits; the separator needs a space
+// on both sides and the last one on the line is used, so regexp alternation like (a|b) is kept.
+// '#' starts a comment
+func loadBotRules(path string) ([]botRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rules := make([]botRule, 0)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		spec, name := splitRuleName(line)
+
+		kind, value, found := strings.Cut(spec, ":")
+		if !found || (kind != "ua" && kind != "re" && kind != "ip") {
+			kind, value = "ua", spec
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, fmt.Errorf("line %d: empty rule", lineNumber)
+		}
+		if name == "" {
+			name = value
+		}
+
+		// Matching visits store the name as their browser
+		rule := botRule{name: util.TruncateRunes(name, util.MaxBrowserLength)}
+		switch kind {
+		case "re":
+			pattern, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			rule.pattern = pattern
+		case "ip":
+			if !strings.Contains(value, "/") {
+				if strings.Contains(value, ":") {
+					value += "/128"
+				} else {
+					value += "/32"
+				}
+			}
+			_, network, err := net.ParseCIDR(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			rule.network = network
+		default:
+			rule.token = strings.ToLower(value)
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
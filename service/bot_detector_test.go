@@ -0,0 +1,69 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func writeBotRules(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bots.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadBotRulesKeepsRegexpAlternation(t *testing.T) {
+	path := writeBotRules(t, `# monitors
+re:(foo|bar)bot | FooBar
+re:^(Status|Uptime)Probe/\d+
+ua:StatusCake | StatusCake
+ip:203.0.113.0/24 | Internal probe
+`)
+
+	detector := NewBotDetector(path)
+	tests := []struct {
+		userAgent string
+		ip        string
+		want      string
+		matched   bool
+	}{
+		{"Mozilla/5.0 (compatible; foobot/1.0)", "", "FooBar", true},
+		{"Mozilla/5.0 (compatible; barbot/1.0)", "", "FooBar", true},
+		{"UptimeProbe/2", "", `^(Status|Uptime)Probe/\d+`, true},
+		{"Mozilla/5.0 StatusCake", "", "StatusCake", true},
+		{"Mozilla/5.0", "203.0.113.7", "Internal probe", true},
+		{"Mozilla/5.0 (Windows NT 10.0) Chrome/120.0", "198.51.100.1", "", false},
+	}
+	for _, tt := range tests {
+		name, matched := detector.Match(tt.userAgent, tt.ip)
+		if matched != tt.matched || name != tt.want {
+			t.Errorf("Match(%q, %q) = %q, %v; want %q, %v", tt.userAgent, tt.ip, name, matched, tt.want, tt.matched)
+		}
+	}
+}
+
+func TestLoadBotRulesRejectsInvalidRules(t *testing.T) {
+	for _, content := range []string{"re:(foo | Broken\n", "ip:not-an-address\n", "ua: | Empty\n"} {
+		if _, err := loadBotRules(writeBotRules(t, content)); err == nil {
+			t.Errorf("loadBotRules(%q) succeeded, want an error", content)
+		}
+	}
+}
+
+func TestLoadBotRulesCapsNames(t *testing.T) {
+	pattern := "re:^Mozilla/5\\.0 \\(compatible; (InternalLinkChecker|LegacyStatusMonitor)/[0-9.]+; ünicode\\)$"
+	rules, err := loadBotRules(writeBotRules(t, pattern+"\nua:probe | "+strings.Repeat("é", 60)+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range rules {
+		if n := utf8.RuneCountInString(rule.name); n != 50 || !utf8.ValidString(rule.name) {
+			t.Errorf("rule name %q has %d characters, want 50", rule.name, n)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"log"
+	"net"
+	"os"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/Debsnil24/URL_Shortner.git/util"
+)
+
+// maxGeoNameLength matches the region and city columns
+const maxGeoNameLength = 100
+
+// GeoLocation is the place a visitor's IP address resolves to
+type GeoLocation struct {
+	Country string // ISO 3166-1 alpha-2 code, e.g. "IN"
+	Region  string // First-level subdivision name, e.g. "Karnataka"
+	City    string
+}
+
+// GeoLocator resolves IP addresses against a local MaxMind-format city or
+// country database; it never makes network calls
+type GeoLocator struct {
+	reader *util.MMDBReader
+}
+
+var (
+	geoLocatorInstance *GeoLocator
+	geoLocatorOnce     sync.Once
+)
+
+// GetGeoLocator returns a singleton GeoLocator configured from GEOIP_DB_PATH
+// When the variable is unset or the file cannot be read, lookups return no location
+func GetGeoLocator() *GeoLocator {
+	geoLocatorOnce.Do(func() {
+		geoLocatorInstance = NewGeoLocator(os.Getenv("GEOIP_DB_PATH"))
+	})
+	return geoLocatorInstance
+}
+
+// NewGeoLocator creates a GeoLocator backed by the database at path (for testing or custom config)
+// For production use, prefer GetGeoLocator() singleton
+func NewGeoLocator(path string) *GeoLocator {
+	g := &GeoLocator{}
+	if path == "" {
+		return g
+	}
+
+	reader, err := util.OpenMMDB(path)
+	if err != nil {
+		log.Printf("event=geoip_unavailable path=%s err=%v", path, err)
+		return g
+	}
+	g.reader = reader
+
+	log.Printf("event=geoip_loaded path=%s type=%s", path, reader.DatabaseType)
+	return g
+}
+
+// Enabled reports whether a database is loaded
+func (g *GeoLocator) Enabled() bool {
+	return g != nil && g.reader != nil
+}
+
+// Lookup returns the location of ipAddress, or false when it cannot be resolved
+// Private, loopback and unknown addresses are never found
+func (g *GeoLocator) Lookup(ipAddress string) (GeoLocation, bool) {
+	if !g.Enabled() {
+		return GeoLocation{}, false
+	}
+
+	ip := net.ParseIP(ipAddress)
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() {
+		return GeoLocation{}, false
+	}
+
+	record, err := g.reader.Lookup(ip)
+	if err != nil || record == nil {
+		return GeoLocation{}, false
+	}
+
+	location := GeoLocation{
+		Country: geoString(record, "country", "iso_code"),
+		City:    truncateGeoName(geoString(record, "city", "names", "en")),
+	}
+	if location.Country == "" {
+		// Anonymous proxies and satellite providers only carry the registered country
+		location.Country = geoString(record, "registered_country", "iso_code")
+	}
+	if len(location.Country) != 2 {
+		location.Country = "" // Not an ISO code; it would not fit the country column
+	}
+	if subdivisions, ok := record["subdivisions"].([]interface{}); ok && len(subdivisions) > 0 {
+		if first, ok := subdivisions[0].(map[string]interface{}); ok {
+			location.Region = truncateGeoName(geoString(first, "names", "en"))
+		}
+	}
+
+	if location.Country == "" && location.Region == "" && location.City == "" {
+		return GeoLocation{}, false
+	}
+	return location, true
+}
+
+// truncateGeoName cuts a place name to the column size on a character boundary
+func truncateGeoName(name string) string {
+	if utf8.RuneCountInString(name) <= maxGeoNameLength {
+		return name
+	}
+	return string([]rune(name)[:maxGeoNameLength])
+}
+
+// geoString walks nested maps in a database record and returns the string at the end of path
+func geoString(record map[string]interface{}, path ...string) string {
+	current := record
+	for i, key := range path {
+		value, ok := current[key]
+		if !ok {
+			return ""
+		}
+		if i == len(path)-1 {
+			s, _ := value.(string)
+			return s
+		}
+		if current, ok = value.(map[string]interface{}); !ok {
+			return ""
+		}
+	}
+	return ""
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Visitor IP handling modes
+const (
+	IPModeRaw      = "raw"      // Store the address as received
+	IPModeTruncate = "truncate" // Zero the host part: IPv4 to /24, IPv6 to /48
+	IPModeHash     = "hash"     // Store a keyed hash that changes every UTC day
+)
+
+// hashedIPPrefix marks stored values that are hashes rather than addresses
+const hashedIPPrefix = "h:"
+
+// SaltStore shares the daily hash salts between instances
+// DaySalt returns the salt stored for a UTC day, storing candidate first when the day has none,
+// and deletes the salts of days that are over so past hashes can no longer be recomputed
+type SaltStore interface {
+	DaySalt(day string, candidate []byte) ([]byte, error)
+}
+
+// IPAnonymizer converts visitor IP addresses into the form that may be stored
+type IPAnonymizer struct {
+	mode string
+
+	mu    sync.Mutex
+	store SaltStore         // Shared salts; without one, salts are random and held in memory
+	salts map[string][]byte // The current day's salt
+}
+
+var (
+	ipAnonymizerInstance *IPAnonymizer
+	ipAnonymizerOnce     sync.Once
+)
+
+// GetIPAnonymizer returns a singleton IPAnonymizer configured from IP_PRIVACY_MODE
+func GetIPAnonymizer() *IPAnonymizer {
+	ipAnonymizerOnce.Do(func() {
+		ipAnonymizerInstance = NewIPAnonymizer(os.Getenv("IP_PRIVACY_MODE"))
+	})
+	return ipAnonymizerInstance
+}
+
+// NewIPAnonymizer creates an IPAnonymizer for the given mode (for testing or custom config)
+// Unknown modes fall back to raw; for production use, prefer GetIPAnonymizer() singleton
+func NewIPAnonymizer(mode string) *IPAnonymizer {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case IPModeRaw, IPModeTruncate, IPModeHash:
+	case "":
+		mode = IPModeRaw
+	default:
+		log.Printf("event=ip_privacy_error reason=unknown_mode mode=%s", mode)
+		mode = IPModeRaw
+	}
+
+	return &IPAnonymizer{
+		mode:  mode,
+		salts: make(map[string][]byte),
+	}
+}
+
+// UseSaltStore shares daily salts through store so every instance and restart hashes an
+// address the same way within a day
+func (a *IPAnonymizer) UseSaltStore(store SaltStore) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.store = store
+	a.salts = make(map[string][]byte)
+}
+
+// Mode returns the configured IP handling mode
+func (a *IPAnonymizer) Mode() string {
+	if a == nil {
+		return IPModeRaw
+	}
+	return a.mode
+}
+
+// Anonymize returns the form of ipAddress to store for a visit made at the given time
+// Within one UTC day the same address always maps to the same value, so distinct
+// counts keep working in every mode
+func (a *IPAnonymizer) Anonymize(ipAddress string, at time.Time) string {
+	switch a.Mode() {
+	case IPModeTruncate:
+		return TruncateIP(ipAddress)
+	case IPModeHash:
+		return a.hashIP(ipAddress, at)
+	default:
+		return ipAddress
+	}
+}
+
+// IsAnonymized reports whether a stored value is already a hash produced by this package
+func IsAnonymized(stored string) bool {
+	return strings.HasPrefix(stored, hashedIPPrefix)
+}
+
+// TruncateIP zeroes the host part of an address: IPv4 to its /24 and IPv6 to its /48
+// Values that are not IP addresses are dropped rather than stored as received
+func TruncateIP(ipAddress string) string {
+	ip := net.ParseIP(strings.TrimSpace(ipAddress))
+	if ip == nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// hashIP returns a truncated HMAC of the address keyed by the salt for the visit's day
+func (a *IPAnonymizer) hashIP(ipAddress string, at time.Time) string {
+	if ipAddress == "" || IsAnonymized(ipAddress) {
+		return ipAddress
+	}
+
+	mac := hmac.New(sha256.New, a.DaySalt(at))
+	mac.Write([]byte(ipAddress))
+	return hashedIPPrefix + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// DaySalt returns the salt for the UTC day of at, creating it on first use
+// Salts are random and never derived from configuration, so once a day's salt is deleted
+// its hashes cannot be recomputed or linked to later ones. Only the current day's salt is
+// kept in memory; visits from another day (queued across midnight) look theirs up each time
+func (a *IPAnonymizer) DaySalt(at time.Time) []byte {
+	day := at.UTC().Format("2006-01-02")
+	today := day == time.Now().UTC().Format("2006-01-02")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if salt, ok := a.salts[day]; ok {
+		return salt
+	}
+
+	salt := NewSalt()
+	if a.store != nil {
+		shared, err := a.store.DaySalt(day, salt)
+		if err != nil {
+			// Hash with a local salt rather than store the address; the shared salt is retried next time
+			log.Printf("event=ip_privacy_error reason=salt_store_failed day=%s err=%v", day, err)
+			return salt
+		}
+		salt = shared
+	}
+	if today {
+		// Replaces the previous day's salt, which is forgotten
+		a.salts = map[string][]byte{day: salt}
+	}
+	return salt
+}
+
+// NewSalt returns 32 random bytes for keying IP hashes
+func NewSalt() []byte {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		log.Printf("event=ip_privacy_error reason=salt_generation_failed err=%v", err)
+	}
+	return salt
+}
+
+// HMACPads returns the inner and outer padded keys of HMAC-SHA256 for key, so the database
+// can compute the same hashes as Anonymize with sha256() when rewriting rows in bulk
+func HMACPads(key []byte) (inner, outer []byte) {
+	if len(key) > sha256.BlockSize {
+		sum := sha256.Sum256(key)
+		key = sum[:]
+	}
+	inner = make([]byte, sha256.BlockSize)
+	outer = make([]byte, sha256.BlockSize)
+	copy(inner, key)
+	copy(outer, key)
+	for i := range inner {
+		inner[i] ^= 0x36
+		outer[i] ^= 0x5c
+	}
+	return inner, outer
+}
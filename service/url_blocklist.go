@@ -0,0 +1,437 @@
+package service
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Actions taken when a new link's destination matches the blocklist
+const (
+	BlocklistActionReject     = "reject"     // Refuse to create the link
+	BlocklistActionQuarantine = "quarantine" // Create the link but never redirect through it
+)
+
+// BlocklistMatch describes why a destination was blocked
+type BlocklistMatch struct {
+	Source string // domain, rule, hash_prefix, or the name of a registered checker
+	Rule   string // The entry that matched
+}
+
+// String formats the match as the reason stored on quarantined links
+func (m BlocklistMatch) String() string {
+	if m.Rule == "" {
+		return m.Source
+	}
+	return m.Source + ": " + m.Rule
+}
+
+// URLChecker is a source of malicious-URL verdicts that can be plugged into a URLBlocklist
+type URLChecker interface {
+	Check(destination *url.URL) (BlocklistMatch, bool)
+}
+
+// blocklistSource is one file-backed checker, reloaded whenever its file changes
+type blocklistSource struct {
+	name    string // BlocklistMatch.Source of the matches it reports
+	path    string
+	load    func(path string) (URLChecker, error)
+	checker URLChecker
+	modTime time.Time
+	failed  bool // The last load attempt failed, so checker may be missing or stale
+}
+
+// URLBlocklist checks link destinations against local domain, rule and hash prefix lists
+// plus any registered checkers, reloading the files whenever they change
+type URLBlocklist struct {
+	action  string
+	mu      sync.RWMutex
+	sources []*blocklistSource
+	extra   []URLChecker
+}
+
+var (
+	urlBlocklistInstance *URLBlocklist
+	urlBlocklistOnce     sync.Once
+)
+
+// GetURLBlocklist returns a singleton URLBlocklist configured from BLOCKLIST_DOMAINS_FILE,
+// BLOCKLIST_RULES_FILE, BLOCKLIST_HASH_PREFIXES_FILE and BLOCKLIST_ACTION
+// When no file is configured nothing is blocked
+func GetURLBlocklist() *URLBlocklist {
+	urlBlocklistOnce.Do(func() {
+		urlBlocklistInstance = NewURLBlocklist(
+			os.Getenv("BLOCKLIST_ACTION"),
+			os.Getenv("BLOCKLIST_DOMAINS_FILE"),
+			os.Getenv("BLOCKLIST_RULES_FILE"),
+			os.Getenv("BLOCKLIST_HASH_PREFIXES_FILE"),
+		)
+		if len(urlBlocklistInstance.sources) > 0 {
+			go urlBlocklistInstance.watch(30 * time.Second)
+		}
+	})
+	return urlBlocklistInstance
+}
+
+// NewURLBlocklist creates a URLBlocklist from the given files; empty paths are skipped
+// An unknown action falls back to reject. For production use, prefer GetURLBlocklist() singleton
+func NewURLBlocklist(action, domainsPath, rulesPath, hashPrefixesPath string) *URLBlocklist {
+	action = strings.ToLower(strings.TrimSpace(action))
+	if action != BlocklistActionQuarantine {
+		if action != "" && action != BlocklistActionReject {
+			log.Printf("Invalid BLOCKLIST_ACTION %q, using %s", action, BlocklistActionReject)
+		}
+		action = BlocklistActionReject
+	}
+
+	b := &URLBlocklist{action: action}
+	for _, source := range []*blocklistSource{
+		{name: "domain", path: domainsPath, load: loadDomainList},
+		{name: "rule", path: rulesPath, load: loadURLRules},
+		{name: "hash_prefix", path: hashPrefixesPath, load: loadHashPrefixes},
+	} {
+		if source.path == "" {
+			continue
+		}
+		b.sources = append(b.sources, source)
+		if err := b.reload(source); err != nil {
+			log.Printf("event=blocklist_error path=%s err=%v", source.path, err)
+		}
+	}
+	return b
+}
+
+// Register plugs an additional checker into the blocklist
+func (b *URLBlocklist) Register(checker URLChecker) {
+	b.mu.Lock()
+	b.extra = append(b.extra, checker)
+	b.mu.Unlock()
+}
+
+// Action returns what should happen to new links whose destination is blocked
+func (b *URLBlocklist) Action() string {
+	if b == nil {
+		return BlocklistActionReject
+	}
+	return b.action
+}
+
+// Check reports whether a destination URL matches any list or registered checker
+func (b *URLBlocklist) Check(rawURL string) (BlocklistMatch, bool) {
+	if b == nil {
+		return BlocklistMatch{}, false
+	}
+
+	destination, err := url.Parse(rawURL)
+	if err != nil || destination.Hostname() == "" {
+		return BlocklistMatch{}, false
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, source := range b.sources {
+		if source.checker == nil {
+			continue
+		}
+		if match, ok := source.checker.Check(destination); ok {
+			return match, true
+		}
+	}
+	for _, checker := range b.extra {
+		if match, ok := checker.Check(destination); ok {
+			return match, true
+		}
+	}
+	return BlocklistMatch{}, false
+}
+
+// Verifies reports whether a reason stored by an earlier check can be re-evaluated now, that is
+// whether the source that produced it is configured and its file loaded successfully
+// Reasons from registered checkers always can, since they answer each check directly
+func (b *URLBlocklist) Verifies(reason string) bool {
+	if b == nil {
+		return false
+	}
+	name, _, _ := strings.Cut(reason, ": ")
+	switch name {
+	case "domain", "rule", "hash_prefix":
+	default:
+		return true
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, source := range b.sources {
+		if source.name == name {
+			return source.checker != nil && !source.failed
+		}
+	}
+	return false
+}
+
+// reload re-reads one source file, keeping its previous contents if the file cannot be parsed
+func (b *URLBlocklist) reload(source *blocklistSource) error {
+	info, err := os.Stat(source.path)
+	if err != nil {
+		b.markFailed(source)
+		return err
+	}
+
+	checker, err := source.load(source.path)
+	if err != nil {
+		b.markFailed(source)
+		return err
+	}
+
+	b.mu.Lock()
+	source.checker = checker
+	source.modTime = info.ModTime()
+	source.failed = false
+	b.mu.Unlock()
+
+	log.Printf("event=blocklist_loaded path=%s", source.path)
+	return nil
+}
+
+// markFailed records that a source's file could not be loaded
+func (b *URLBlocklist) markFailed(source *blocklistSource) {
+	b.mu.Lock()
+	source.failed = true
+	b.mu.Unlock()
+}
+
+// watch reloads each source file whenever its modification time changes
+func (b *URLBlocklist) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, source := range b.sources {
+			info, err := os.Stat(source.path)
+			if err != nil {
+				b.markFailed(source)
+				continue
+			}
+
+			b.mu.RLock()
+			changed := !info.ModTime().Equal(source.modTime)
+			b.mu.RUnlock()
+
+			if changed {
+				if err := b.reload(source); err != nil {
+					log.Printf("event=blocklist_error path=%s err=%v", source.path, err)
+				}
+			}
+		}
+	}
+}
+
+// readListLines calls parse for every non-empty line of a list file that is not a '#' comment
+func readListLines(path string, parse func(line string, lineNumber int) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := parse(line, lineNumber); err != nil {
+			return fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// splitRuleName splits a list file line into its rule and the optional name after " | "
+// The last separator on the line is used, so regexp alternation like (a|b) or (a | b) stays
+// in the rule; the name is empty when the line has no separator
+func splitRuleName(line string) (spec, name string) {
+	i := strings.LastIndex(line, " | ")
+	if i < 0 {
+		return line, ""
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+3:])
+}
+
+// domainList blocks hosts and all of their subdomains
+type domainList map[string]struct{}
+
+// loadDomainList parses a file with one domain per line; "*." and "." prefixes are ignored
+func loadDomainList(path string) (URLChecker, error) {
+	domains := make(domainList)
+	err := readListLines(path, func(line string, _ int) error {
+		domain := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(line), "*."), ".")
+		domains[strings.TrimSuffix(domain, ".")] = struct{}{}
+		return nil
+	})
+	return domains, err
+}
+
+// Check matches the host and each of its parent domains
+func (d domainList) Check(destination *url.URL) (BlocklistMatch, bool) {
+	host := strings.TrimSuffix(strings.ToLower(destination.Hostname()), ".")
+	for host != "" {
+		if _, ok := d[host]; ok {
+			return BlocklistMatch{Source: "domain", Rule: host}, true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return BlocklistMatch{}, false
+}
+
+// urlRule is a regular expression matched against the whole destination URL
+type urlRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// urlRules blocks destinations matching any of its regular expressions
+type urlRules []urlRule
+
+// loadURLRules parses a file with one regular expression per line, optionally followed by " | <name>"
+// Like bot rules, the last separator on the line starts the name, so alternation like (a | b) is kept
+func loadURLRules(path string) (URLChecker, error) {
+	rules := make(urlRules, 0)
+	err := readListLines(path, func(line string, _ int) error {
+		spec, name := splitRuleName(line)
+		pattern, err := regexp.Compile(spec)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			name = spec
+		}
+		rules = append(rules, urlRule{name: name, pattern: pattern})
+		return nil
+	})
+	return rules, err
+}
+
+// Check matches the rules against the destination URL
+func (r urlRules) Check(destination *url.URL) (BlocklistMatch, bool) {
+	value := destination.String()
+	for _, rule := range r {
+		if rule.pattern.MatchString(value) {
+			return BlocklistMatch{Source: "rule", Rule: rule.name}, true
+		}
+	}
+	return BlocklistMatch{}, false
+}
+
+// hashPrefixList blocks destinations whose Safe Browsing–style URL expressions hash to a listed prefix
+type hashPrefixList struct {
+	prefixes map[string]struct{} // Raw prefix bytes
+	lengths  []int               // Distinct prefix lengths present in the list
+}
+
+// loadHashPrefixes parses a file with one hex-encoded SHA-256 prefix (4 to 32 bytes) per line
+func loadHashPrefixes(path string) (URLChecker, error) {
+	list := &hashPrefixList{prefixes: make(map[string]struct{})}
+	seenLengths := make(map[int]bool)
+	err := readListLines(path, func(line string, _ int) error {
+		prefix, err := hex.DecodeString(line)
+		if err != nil {
+			return fmt.Errorf("invalid hex prefix %q", line)
+		}
+		if len(prefix) < 4 || len(prefix) > sha256.Size {
+			return fmt.Errorf("prefix %q must be between 4 and 32 bytes", line)
+		}
+		list.prefixes[string(prefix)] = struct{}{}
+		if !seenLengths[len(prefix)] {
+			seenLengths[len(prefix)] = true
+			list.lengths = append(list.lengths, len(prefix))
+		}
+		return nil
+	})
+	return list, err
+}
+
+// Check hashes every host suffix and path prefix expression of the destination
+// A prefix match is treated as a hit since no full-hash service is consulted
+func (l *hashPrefixList) Check(destination *url.URL) (BlocklistMatch, bool) {
+	for _, expression := range urlExpressions(destination) {
+		sum := sha256.Sum256([]byte(expression))
+		for _, length := range l.lengths {
+			if _, ok := l.prefixes[string(sum[:length])]; ok {
+				return BlocklistMatch{Source: "hash_prefix", Rule: hex.EncodeToString(sum[:length])}, true
+			}
+		}
+	}
+	return BlocklistMatch{}, false
+}
+
+// urlExpressions returns the host suffix / path prefix combinations Safe Browsing hashes for a URL:
+// the exact host and up to four parent domains (never the bare top-level domain), each combined with
+// the exact path and query, the exact path, and up to four leading path prefixes
+func urlExpressions(destination *url.URL) []string {
+	host := strings.TrimSuffix(strings.ToLower(destination.Hostname()), ".")
+
+	hosts := []string{host}
+	if net.ParseIP(host) == nil {
+		labels := strings.Split(host, ".")
+		if len(labels) > 5 {
+			labels = labels[len(labels)-5:]
+		}
+		for i := 1; i < len(labels)-1 && i <= 4; i++ {
+			suffix := strings.Join(labels[i:], ".")
+			if suffix != host {
+				hosts = append(hosts, suffix)
+			}
+		}
+	}
+
+	path := destination.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	paths := make([]string, 0, 6)
+	if destination.RawQuery != "" {
+		paths = append(paths, path+"?"+destination.RawQuery)
+	}
+	paths = append(paths, path)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	prefix := "/"
+	for i := 0; i < len(segments) && i < 4; i++ {
+		if prefix != path {
+			paths = append(paths, prefix)
+		}
+		if segments[i] == "" {
+			break
+		}
+		prefix += segments[i] + "/"
+	}
+
+	expressions := make([]string, 0, len(hosts)*len(paths))
+	seen := make(map[string]bool)
+	for _, h := range hosts {
+		for _, p := range paths {
+			expression := h + p
+			if !seen[expression] {
+				seen[expression] = true
+				expressions = append(expressions, expression)
+			}
+		}
+	}
+	return expressions
+}
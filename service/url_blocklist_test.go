@@ -0,0 +1,38 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestLoadURLRulesKeepsRegexpAlternation(t *testing.T) {
+	path := writeBotRules(t, `# spam campaigns
+[?&]q=(free | prize) | Giveaway
+^https?://[^/]+/wp-(admin|content)/.*\.exe$
+`)
+
+	checker, err := loadURLRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		destination string
+		want        string
+		matched     bool
+	}{
+		{"https://example.com/?q=free gift", "Giveaway", true},
+		{"https://example.com/?q= prize", "Giveaway", true},
+		{"https://example.com/?q=free", "", false},
+		{"https://example.com/wp-content/x.exe", `^https?://[^/]+/wp-(admin|content)/.*\.exe$`, true},
+	}
+	for _, tt := range tests {
+		destination, err := url.Parse(tt.destination)
+		if err != nil {
+			t.Fatal(err)
+		}
+		match, matched := checker.Check(destination)
+		if matched != tt.matched || match.Rule != tt.want {
+			t.Errorf("Check(%q) = %+v, %v; want rule %q, %v", tt.destination, match, matched, tt.want, tt.matched)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Debsnil24/URL_Shortner.git/util"
+)
+
+// URL cache backends
+const (
+	URLCacheMemory = "memory" // In-process LRU, private to each instance
+	URLCacheRedis  = "redis"  // Shared Redis-protocol server
+	URLCacheOff    = "off"
+)
+
+// Default URL cache limits
+const (
+	defaultURLCacheSize        = 10000
+	defaultURLCacheTTL         = 5 * time.Minute
+	defaultURLCacheNegativeTTL = 30 * time.Second
+)
+
+// urlCacheKeyPrefix namespaces short code entries in a shared Redis database
+const urlCacheKeyPrefix = "sniply:url:"
+
+// CacheBackend stores opaque values with a per-entry TTL
+type CacheBackend interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(keys ...string) error
+}
+
+// memoryBackend adapts util.LRUCache to CacheBackend
+type memoryBackend struct {
+	lru *util.LRUCache
+}
+
+func (m memoryBackend) Get(key string) ([]byte, bool, error) {
+	value, ok := m.lru.Get(key)
+	return value, ok, nil
+}
+
+func (m memoryBackend) Set(key string, value []byte, ttl time.Duration) error {
+	m.lru.Set(key, value, ttl)
+	return nil
+}
+
+func (m memoryBackend) Delete(keys ...string) error {
+	m.lru.Delete(keys...)
+	return nil
+}
+
+// NewMemoryCacheBackend creates an in-process LRU backend holding at most size entries
+// Invalidation only reaches the instance that made the change: on a multi-instance deploy other
+// instances keep serving their copy, including a link that was since edited, deleted or
+// blocklisted, until it expires after the cache TTL. Use the Redis backend there
+func NewMemoryCacheBackend(size int) CacheBackend {
+	return memoryBackend{lru: util.NewLRUCache(size)}
+}
+
+// NewRedisCacheBackend creates a backend on the Redis-protocol server at rawURL
+// (redis://[[user]:password@]host[:port][/db]); it can point at any local stand-in in tests
+func NewRedisCacheBackend(rawURL string, timeout time.Duration) (CacheBackend, error) {
+	return util.NewRedisClient(rawURL, timeout)
+}
+
+// URLCache caches short code lookups for the redirect path, including codes known not to exist
+// Backend errors are logged and treated as misses so the database remains the source of truth
+type URLCache struct {
+	backend     CacheBackend
+	ttl         time.Duration
+	negativeTTL time.Duration
+	generation  atomic.Uint64 // Bumped by every Invalidate
+}
+
+var (
+	urlCacheInstance *URLCache
+	urlCacheOnce     sync.Once
+)
+
+// GetURLCache returns a singleton URLCache configured from URL_CACHE, URL_CACHE_SIZE, URL_CACHE_TTL,
+// URL_CACHE_NEGATIVE_TTL and URL_CACHE_REDIS_URL; it is nil when caching is turned off
+func GetURLCache() *URLCache {
+	urlCacheOnce.Do(func() {
+		urlCacheInstance = newURLCacheFromEnv()
+	})
+	return urlCacheInstance
+}
+
+// newURLCacheFromEnv builds the configured cache, falling back to the in-memory backend
+// when the Redis backend is misconfigured
+func newURLCacheFromEnv() *URLCache {
+	ttl := envDuration("URL_CACHE_TTL", defaultURLCacheTTL)
+	negativeTTL := envDuration("URL_CACHE_NEGATIVE_TTL", defaultURLCacheNegativeTTL)
+
+	size := defaultURLCacheSize
+	if raw := strings.TrimSpace(os.Getenv("URL_CACHE_SIZE")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			log.Printf("Invalid URL_CACHE_SIZE %q, using %d", raw, defaultURLCacheSize)
+		} else {
+			size = parsed
+		}
+	}
+
+	backend := strings.ToLower(strings.TrimSpace(os.Getenv("URL_CACHE")))
+	switch backend {
+	case URLCacheOff:
+		log.Printf("event=url_cache_configured backend=off")
+		return nil
+	case URLCacheRedis:
+		redis, err := NewRedisCacheBackend(os.Getenv("URL_CACHE_REDIS_URL"), time.Second)
+		if err == nil {
+			log.Printf("event=url_cache_configured backend=redis ttl=%s negative_ttl=%s", ttl, negativeTTL)
+			return NewURLCache(redis, ttl, negativeTTL)
+		}
+		log.Printf("event=url_cache_error reason=invalid_redis_url err=%v", err)
+	case "", URLCacheMemory:
+	default:
+		log.Printf("Invalid URL_CACHE %q, using %s", backend, URLCacheMemory)
+	}
+
+	log.Printf("event=url_cache_configured backend=memory size=%d ttl=%s negative_ttl=%s", size, ttl, negativeTTL)
+	return NewURLCache(NewMemoryCacheBackend(size), ttl, negativeTTL)
+}
+
+// envDuration reads a Go duration from the environment, using fallback when unset or invalid
+func envDuration(name string, fallback time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(raw)
+	if err != nil || parsed <= 0 {
+		log.Printf("Invalid %s %q, using %s", name, raw, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// NewURLCache creates a URLCache on the given backend (for testing or custom config)
+// For production use, prefer GetURLCache() singleton
+func NewURLCache(backend CacheBackend, ttl, negativeTTL time.Duration) *URLCache {
+	return &URLCache{backend: backend, ttl: ttl, negativeTTL: negativeTTL}
+}
+
+// Get returns the cached entry for a short code; a hit with an empty value means the code
+// is known not to exist
+func (c *URLCache) Get(code string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	value, ok, err := c.backend.Get(urlCacheKeyPrefix + code)
+	if err != nil {
+		log.Printf("event=url_cache_error op=get code=%s err=%v", code, err)
+		return nil, false
+	}
+	return value, ok
+}
+
+// Generation returns the cache's invalidation counter; callers read it before loading a link
+// from the database and pass it to Set or SetMissing
+func (c *URLCache) Generation() uint64 {
+	if c == nil {
+		return 0
+	}
+	return c.generation.Load()
+}
+
+// Set caches the encoded link for a short code unless an invalidation happened since generation
+// was read, in which case the value may already be stale and is dropped
+func (c *URLCache) Set(code string, value []byte, generation uint64) {
+	if c == nil || len(value) == 0 || c.generation.Load() != generation {
+		return
+	}
+	if err := c.backend.Set(urlCacheKeyPrefix+code, value, c.ttl); err != nil {
+		log.Printf("event=url_cache_error op=set code=%s err=%v", code, err)
+	}
+}
+
+// SetMissing records that a short code does not exist, so repeated lookups of unknown
+// codes skip the database for the negative TTL; like Set it is skipped after an invalidation
+// Invalidations on other instances are not seen here, so callers should re-check the database
+// afterwards and invalidate the code if it was created meanwhile
+func (c *URLCache) SetMissing(code string, generation uint64) {
+	if c == nil || c.negativeTTL <= 0 || c.generation.Load() != generation {
+		return
+	}
+	if err := c.backend.Set(urlCacheKeyPrefix+code, []byte{}, c.negativeTTL); err != nil {
+		log.Printf("event=url_cache_error op=set_missing code=%s err=%v", code, err)
+	}
+}
+
+// Invalidate drops the cached entries of the given short codes
+func (c *URLCache) Invalidate(codes ...string) {
+	if c == nil || len(codes) == 0 {
+		return
+	}
+	c.generation.Add(1)
+	keys := make([]string, len(codes))
+	for i, code := range codes {
+		keys[i] = urlCacheKeyPrefix + code
+	}
+	if err := c.backend.Delete(keys...); err != nil {
+		log.Printf("event=url_cache_error op=invalidate codes=%d err=%v", len(codes), err)
+	}
+}
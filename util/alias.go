@@ -0,0 +1,59 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// AliasMinLength is the shortest custom alias accepted
+	AliasMinLength = 3
+	// AliasMaxLength matches the size of the urls.short_code column
+	AliasMaxLength = 10
+)
+
+// reservedAliases contains path segments that a custom alias must never shadow
+var reservedAliases = map[string]struct{}{
+	"api":      {},
+	"auth":     {},
+	"admin":    {},
+	"login":    {},
+	"logout":   {},
+	"register": {},
+	"health":   {},
+	"static":   {},
+	"assets":   {},
+	"support":  {},
+}
+
+// ValidateAlias checks that a caller-supplied alias is usable as a short code
+// Aliases may contain letters, digits, '-' and '_' and must not be a reserved word
+func ValidateAlias(alias string) error {
+	if len(alias) < AliasMinLength || len(alias) > AliasMaxLength {
+		return fmt.Errorf("alias must be between %d and %d characters", AliasMinLength, AliasMaxLength)
+	}
+
+	for _, r := range alias {
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		isDigit := r >= '0' && r <= '9'
+		if !isLetter && !isDigit && r != '-' && r != '_' {
+			return fmt.Errorf("alias may only contain letters, digits, '-' and '_'")
+		}
+	}
+
+	if strings.HasPrefix(alias, "-") || strings.HasPrefix(alias, "_") {
+		return fmt.Errorf("alias must start with a letter or digit")
+	}
+
+	if IsReservedAlias(alias) {
+		return fmt.Errorf("alias %q is reserved", alias)
+	}
+
+	return nil
+}
+
+// IsReservedAlias reports whether the alias collides with a reserved route segment
+func IsReservedAlias(alias string) bool {
+	_, reserved := reservedAliases[strings.ToLower(alias)]
+	return reserved
+}
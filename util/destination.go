@@ -0,0 +1,13 @@
+package util
+
+import "strings"
+
+// DestinationKey returns the form of a destination URL used to recognise links that point to the same place
+// It is the URL as URLPolicy.Normalize stores it, so new links and links saved before normalisation
+// share one canonical form; values Normalize rejects are returned trimmed but otherwise unchanged
+func DestinationKey(raw string) string {
+	if normalized, err := (URLPolicy{}).Normalize(raw); err == nil {
+		return normalized
+	}
+	return strings.TrimSpace(raw)
+}
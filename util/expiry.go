@@ -0,0 +1,130 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpiryPolicy bounds the lifetime callers may request for a short link
+type ExpiryPolicy struct {
+	Default    time.Duration // Lifetime applied when the caller does not ask for one
+	Min        time.Duration // Shortest lifetime accepted
+	Max        time.Duration // Longest lifetime accepted; zero means no upper bound
+	AllowNever bool          // Whether links may be created without an expiry
+}
+
+// maxTTLDays bounds "Nd" TTLs well below the int64 nanosecond range of time.Duration
+const maxTTLDays = 100 * 365
+
+// ExpiryRequest holds the mutually exclusive expiry options a caller can send
+type ExpiryRequest struct {
+	ExpiresAt    *time.Time
+	TTL          string
+	NeverExpires bool
+}
+
+// IsEmpty reports whether the caller did not specify any expiry option
+func (r ExpiryRequest) IsEmpty() bool {
+	return r.ExpiresAt == nil && strings.TrimSpace(r.TTL) == "" && !r.NeverExpires
+}
+
+// Resolve turns the caller's expiry options into an absolute expiry time
+// A nil result means the link never expires
+func (p ExpiryPolicy) Resolve(req ExpiryRequest, now time.Time) (*time.Time, error) {
+	set := 0
+	if req.ExpiresAt != nil {
+		set++
+	}
+	if strings.TrimSpace(req.TTL) != "" {
+		set++
+	}
+	if req.NeverExpires {
+		set++
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("only one of expires_at, ttl and never_expires may be set")
+	}
+
+	if req.NeverExpires {
+		if !p.AllowNever {
+			return nil, fmt.Errorf("links without an expiry are not allowed")
+		}
+		return nil, nil
+	}
+
+	var expiresAt time.Time
+	switch {
+	case req.ExpiresAt != nil:
+		expiresAt = *req.ExpiresAt
+	case strings.TrimSpace(req.TTL) != "":
+		ttl, err := ParseTTL(req.TTL)
+		if err != nil {
+			return nil, err
+		}
+		expiresAt = now.Add(ttl)
+	default:
+		lifetime := p.DefaultLifetime()
+		if lifetime <= 0 {
+			if !p.AllowNever {
+				return nil, fmt.Errorf("an expiry is required")
+			}
+			return nil, nil
+		}
+		expiresAt = now.Add(lifetime)
+	}
+
+	lifetime := expiresAt.Sub(now)
+	if lifetime < p.Min || lifetime <= 0 {
+		return nil, fmt.Errorf("expiry must be at least %s in the future", FormatTTL(p.Min))
+	}
+	if p.Max > 0 && lifetime > p.Max {
+		return nil, fmt.Errorf("expiry must be at most %s in the future", FormatTTL(p.Max))
+	}
+
+	return &expiresAt, nil
+}
+
+// DefaultLifetime returns the lifetime given to links created without an expiry, clamped to
+// [Min, Max] so a misconfigured default cannot make every such request fail
+// Zero means the links never expire
+func (p ExpiryPolicy) DefaultLifetime() time.Duration {
+	lifetime := p.Default
+	if lifetime <= 0 {
+		return 0
+	}
+	if p.Max > 0 && lifetime > p.Max {
+		lifetime = p.Max
+	}
+	if lifetime < p.Min {
+		lifetime = p.Min
+	}
+	return lifetime
+}
+
+// ParseTTL parses a Go duration string, additionally accepting a whole number of days such as "30d"
+func ParseTTL(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 || n > maxTTLDays {
+			return 0, fmt.Errorf("invalid ttl %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid ttl %q", value)
+	}
+	return ttl, nil
+}
+
+// FormatTTL renders a duration in days when it is a whole number of days
+func FormatTTL(d time.Duration) string {
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
@@ -0,0 +1,244 @@
+package util
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportedLink is one data row of a link import CSV
+type ImportedLink struct {
+	Line      int        // 1-based line number in the file, for per-row reporting
+	URL       string     // Destination URL
+	Code      string     // Short code to preserve; empty when the row has none
+	Title     string     // Optional link title
+	CreatedAt *time.Time // Original creation date, when given
+	ExpiresAt *time.Time // Expiry carried over from the previous shortener, when given
+	Clicks    int        // Click count carried over from the previous shortener
+	Tags      []string   // Tag names
+	Err       error      // Set when the row could not be parsed
+}
+
+// importColumns maps each import field to the header names that may hold it
+// The names cover our own export, common generic layouts and Bitly's link export
+var importColumns = map[string][]string{
+	"url":     {"original_url", "long_url", "url", "destination", "destination_url", "target_url"},
+	"code":    {"short_code", "code", "alias", "back_half", "custom_bitlinks", "custom_bitlink", "bitlink", "link", "short_url", "short_link"},
+	"title":   {"title", "name"},
+	"created": {"created_at", "created", "date_created", "creation_date"},
+	"expires": {"expires_at", "expires", "expiry", "expiration", "expiration_date"},
+	"clicks":  {"clicks", "click_count", "total_clicks"},
+	"tags":    {"tags", "tag", "labels"},
+}
+
+// CSVFormulaPrefixes are the leading characters a spreadsheet may evaluate as a formula.
+// CSV exports prefix cells starting with one of them with a single quote, which imports strip again
+const CSVFormulaPrefixes = "=+-@\t\r"
+
+// importDateLayouts are the creation date formats accepted in import files
+var importDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700 MST",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"01/02/2006 15:04",
+	"01/02/2006",
+}
+
+// ParseLinkCSV reads a link import CSV with a header row
+// Only a destination URL column is required; problems with individual rows are
+// reported in their Err field so the rest of the file can still be imported
+func ParseLinkCSV(r io.Reader, maxRows int) ([]ImportedLink, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("file is empty")
+		}
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	columns := mapImportColumns(header)
+	if _, ok := columns["url"]; !ok {
+		return nil, errors.New("CSV must have a destination URL column such as original_url or long_url")
+	}
+
+	links := make([]ImportedLink, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			links = append(links, ImportedLink{Line: parseErr.Line, Err: errors.New("malformed CSV row")})
+		} else if !isBlankRecord(record) {
+			line, _ := reader.FieldPos(0)
+			links = append(links, parseImportRecord(record, columns, line))
+		}
+
+		if len(links) > maxRows {
+			return nil, fmt.Errorf("at most %d rows can be imported at once", maxRows)
+		}
+	}
+
+	return links, nil
+}
+
+// mapImportColumns finds the positions of every known field in the header row, in order of preference
+func mapImportColumns(header []string) map[string][]int {
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+		if _, seen := positions[name]; !seen {
+			positions[name] = i
+		}
+	}
+
+	columns := make(map[string][]int, len(importColumns))
+	for field, names := range importColumns {
+		for _, name := range names {
+			if i, ok := positions[name]; ok {
+				columns[field] = append(columns[field], i)
+			}
+		}
+	}
+	return columns
+}
+
+// parseImportRecord converts one CSV record into an ImportedLink
+func parseImportRecord(record []string, columns map[string][]int, line int) ImportedLink {
+	// A field holds the first non-empty value among its columns, so Bitly rows
+	// without a custom back-half fall back to the code in their link column
+	field := func(name string) string {
+		for _, i := range columns[name] {
+			if i < len(record) && strings.TrimSpace(record[i]) != "" {
+				return strings.TrimSpace(record[i])
+			}
+		}
+		return ""
+	}
+
+	link := ImportedLink{
+		Line:  line,
+		URL:   field("url"),
+		Code:  importedCode(field("code")),
+		Title: unquoteCSVFormula(field("title")),
+		Tags:  splitImportTags(unquoteCSVFormula(field("tags"))),
+	}
+	if link.URL == "" {
+		link.Err = errors.New("destination URL is required")
+		return link
+	}
+
+	if created := field("created"); created != "" {
+		createdAt, err := parseImportDate(created)
+		if err != nil {
+			link.Err = fmt.Errorf("invalid created date %q", created)
+			return link
+		}
+		if createdAt.After(time.Now()) {
+			link.Err = fmt.Errorf("created date %q is in the future", created)
+			return link
+		}
+		link.CreatedAt = &createdAt
+	}
+
+	if expires := field("expires"); expires != "" {
+		expiresAt, err := parseImportDate(expires)
+		if err != nil {
+			link.Err = fmt.Errorf("invalid expiry date %q", expires)
+			return link
+		}
+		link.ExpiresAt = &expiresAt
+	}
+
+	if clicks := field("clicks"); clicks != "" {
+		count, err := strconv.Atoi(strings.ReplaceAll(clicks, ",", ""))
+		if err != nil || count < 0 {
+			link.Err = fmt.Errorf("invalid click count %q", clicks)
+			return link
+		}
+		link.Clicks = count
+	}
+
+	return link
+}
+
+// importedCode extracts the short code from a code column, which may hold a bare
+// code or a full short link such as "bit.ly/abc123"; Bitly lists several custom
+// back-halves separated by commas, of which the first is kept
+func importedCode(value string) string {
+	if i := strings.IndexAny(value, ",;|"); i >= 0 {
+		value = value[:i]
+	}
+	value = strings.TrimSpace(value)
+	if i := strings.Index(value, "://"); i >= 0 {
+		value = value[i+3:]
+	}
+	if i := strings.IndexAny(value, "?#"); i >= 0 {
+		value = value[:i]
+	}
+	value = strings.Trim(value, "/")
+	if i := strings.LastIndex(value, "/"); i >= 0 {
+		value = value[i+1:]
+	}
+	return value
+}
+
+// unquoteCSVFormula removes the single quote an export puts in front of a cell that would
+// otherwise be read as a formula, so exported titles and tags re-import unchanged
+func unquoteCSVFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(CSVFormulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// splitImportTags splits a tags cell on commas, semicolons or pipes
+func splitImportTags(value string) []string {
+	tags := make([]string, 0)
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || r == '|' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseImportDate parses a date in any of importDateLayouts, or as Unix seconds
+func parseImportDate(value string) (time.Time, error) {
+	for _, layout := range importDateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// isBlankRecord reports whether every field of a CSV record is empty
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLinkCSV(t *testing.T) {
+	input := "\ufeffOriginal URL,Short Code,Title,Created At,Expires At,Clicks,Tags\n" +
+		"https://example.com/a,promo,Spring sale,2024-03-01T10:00:00Z,2030-01-01,\"1,204\",\"sale; spring\"\n" +
+		"\n" +
+		"https://example.com/b,,,,,,\n" +
+		",missing,,,,,\n" +
+		"https://example.com/c,,,not a date,,,\n" +
+		"https://example.com/d,,,2999-01-01,,,\n" +
+		"https://example.com/e,,,,,-3,\n"
+
+	links, err := ParseLinkCSV(strings.NewReader(input), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 6 {
+		t.Fatalf("got %d rows, want 6 (blank lines skipped)", len(links))
+	}
+
+	first := links[0]
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if first.Err != nil || first.Line != 2 || first.URL != "https://example.com/a" || first.Code != "promo" ||
+		first.Title != "Spring sale" || first.Clicks != 1204 ||
+		first.CreatedAt == nil || !first.CreatedAt.Equal(created) ||
+		first.ExpiresAt == nil || !first.ExpiresAt.Equal(expires) ||
+		!reflect.DeepEqual(first.Tags, []string{"sale", "spring"}) {
+		t.Errorf("first row = %+v", first)
+	}
+
+	if second := links[1]; second.Err != nil || second.Line != 4 || second.Code != "" || second.CreatedAt != nil || second.ExpiresAt != nil {
+		t.Errorf("second row = %+v", second)
+	}
+
+	wantErrors := []struct {
+		line int
+		err  string
+	}{
+		{5, "destination URL is required"},
+		{6, `invalid created date "not a date"`},
+		{7, `created date "2999-01-01" is in the future`},
+		{8, `invalid click count "-3"`},
+	}
+	for n, want := range wantErrors {
+		got := links[2+n]
+		if got.Line != want.line || got.Err == nil || got.Err.Error() != want.err {
+			t.Errorf("row on line %d = %+v, want error %q", want.line, got, want.err)
+		}
+	}
+}
+
+func TestParseLinkCSVBitlyLayout(t *testing.T) {
+	input := "title,long_url,link,custom_bitlinks,created,clicks,tags\n" +
+		"Docs,https://example.com/docs,https://bit.ly/3xYz12,\"https://bit.ly/docs, https://bit.ly/docs-old\",2023-05-04 12:30:00 +0000 UTC,17,docs|help\n" +
+		"Blog,https://example.com/blog,https://bit.ly/4AbC34,,1700000000,0,\n"
+
+	links, err := ParseLinkCSV(strings.NewReader(input), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 {
+		t.Fatalf("got %d rows, want 2", len(links))
+	}
+
+	if links[0].Err != nil || links[0].Code != "docs" || links[0].Clicks != 17 ||
+		!reflect.DeepEqual(links[0].Tags, []string{"docs", "help"}) ||
+		links[0].CreatedAt == nil || !links[0].CreatedAt.Equal(time.Date(2023, 5, 4, 12, 30, 0, 0, time.UTC)) {
+		t.Errorf("custom back-half row = %+v", links[0])
+	}
+	// Without a custom back-half the code comes from the link column
+	if links[1].Err != nil || links[1].Code != "4AbC34" ||
+		links[1].CreatedAt == nil || !links[1].CreatedAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("generated bitlink row = %+v", links[1])
+	}
+}
+
+func TestParseLinkCSVRejectsFiles(t *testing.T) {
+	tests := map[string]string{
+		"empty":          "",
+		"no url column":  "title,clicks\nDocs,3\n",
+		"too many rows":  "url\nhttps://a.example\nhttps://b.example\nhttps://c.example\n",
+		"invalid header": "\"url\n",
+	}
+	for name, input := range tests {
+		if _, err := ParseLinkCSV(strings.NewReader(input), 2); err == nil {
+			t.Errorf("%s: ParseLinkCSV succeeded", name)
+		}
+	}
+}
+
+func TestImportedCode(t *testing.T) {
+	tests := map[string]string{
+		"promo":                           "promo",
+		"  promo  ":                       "promo",
+		"bit.ly/abc123":                   "abc123",
+		"https://bit.ly/abc123":           "abc123",
+		"https://bit.ly/abc123/":          "abc123",
+		"https://bit.ly/abc123?utm=x#top": "abc123",
+		"https://bit.ly/first, https://bit.ly/second": "first",
+		"one;two":                       "one",
+		"https://brand.example/go/sale": "sale",
+		"":                              "",
+	}
+	for input, want := range tests {
+		if got := importedCode(input); got != want {
+			t.Errorf("importedCode(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestParseLinkCSVStripsFormulaGuard(t *testing.T) {
+	// Titles and tags as written by the CSV export, which quotes cells that look like formulas
+	input := "original_url,title,tags\n" +
+		"https://example.com/a,'=SUM(A1),\"'-sale, spring\"\n" +
+		"https://example.com/b,'quoted,'plain\n"
+
+	links, err := ParseLinkCSV(strings.NewReader(input), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := links[0]; got.Title != "=SUM(A1)" || !reflect.DeepEqual(got.Tags, []string{"-sale", "spring"}) {
+		t.Errorf("guarded row = %+v", got)
+	}
+	if got := links[1]; got.Title != "'quoted" || !reflect.DeepEqual(got.Tags, []string{"'plain"}) {
+		t.Errorf("row without a guard = %+v", got)
+	}
+}
@@ -0,0 +1,80 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUCacheGetSet(t *testing.T) {
+	cache := NewLRUCache(4)
+	if _, ok := cache.Get("a"); ok {
+		t.Fatal("empty cache returned a hit")
+	}
+
+	cache.Set("a", []byte("1"), time.Minute)
+	cache.Set("a", []byte("2"), time.Minute)
+	if value, ok := cache.Get("a"); !ok || string(value) != "2" {
+		t.Errorf("Get(a) = %q, %t; want the overwritten value", value, ok)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", cache.Len())
+	}
+
+	// An empty value is a hit, which URLCache uses for codes known not to exist
+	cache.Set("missing", []byte{}, time.Minute)
+	if value, ok := cache.Get("missing"); !ok || len(value) != 0 {
+		t.Errorf("Get(missing) = %q, %t; want an empty hit", value, ok)
+	}
+}
+
+func TestLRUCacheExpiry(t *testing.T) {
+	cache := NewLRUCache(4)
+	cache.Set("short", []byte("x"), time.Millisecond)
+	cache.Set("long", []byte("y"), time.Minute)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := cache.Get("short"); ok {
+		t.Error("expired entry returned a hit")
+	}
+	if _, ok := cache.Get("long"); !ok {
+		t.Error("live entry missing")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Len() = %d, want the expired entry removed on access", cache.Len())
+	}
+}
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewLRUCache(2)
+	cache.Set("a", []byte("1"), time.Minute)
+	cache.Set("b", []byte("2"), time.Minute)
+	cache.Get("a") // b is now the least recently used
+	cache.Set("c", []byte("3"), time.Minute)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("entry %s was evicted", key)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", cache.Len())
+	}
+}
+
+func TestLRUCacheDelete(t *testing.T) {
+	cache := NewLRUCache(0) // Raised to a capacity of 1
+	cache.Set("a", []byte("1"), time.Minute)
+	cache.Delete("a", "unknown")
+	if _, ok := cache.Get("a"); ok || cache.Len() != 0 {
+		t.Error("deleted entry still present")
+	}
+
+	cache.Set("b", []byte("2"), time.Minute)
+	cache.Set("c", []byte("3"), time.Minute)
+	if cache.Len() != 1 {
+		t.Errorf("Len() = %d, want capacity 1", cache.Len())
+	}
+}
@@ -0,0 +1,352 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+)
+
+// mmdbMetadataMarker precedes the metadata map at the end of every MaxMind DB file
+var mmdbMetadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// mmdbDataSeparator is the size of the zero-filled gap between the search tree and the data section
+const mmdbDataSeparator = 16
+
+// MaxMind DB data section field types
+const (
+	mmdbExtended  = 0
+	mmdbPointer   = 1
+	mmdbString    = 2
+	mmdbDouble    = 3
+	mmdbBytes     = 4
+	mmdbUint16    = 5
+	mmdbUint32    = 6
+	mmdbMap       = 7
+	mmdbInt32     = 8
+	mmdbUint64    = 9
+	mmdbUint128   = 10
+	mmdbArray     = 11
+	mmdbContainer = 12
+	mmdbEndMarker = 13
+	mmdbBool      = 14
+	mmdbFloat     = 15
+)
+
+// MMDBReader looks up IP addresses in a MaxMind DB (.mmdb) file, such as the
+// GeoLite2 City database, without any network access
+type MMDBReader struct {
+	buffer       []byte
+	data         []byte // Data section, the base for all data offsets and pointers
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	ipv4Start    uint // Node reached after the 96 zero bits of an IPv4-mapped address
+	DatabaseType string
+}
+
+// OpenMMDB reads a MaxMind DB file into memory and validates its metadata
+func OpenMMDB(path string) (*MMDBReader, error) {
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewMMDBReader(buffer)
+}
+
+// NewMMDBReader creates a reader over the raw contents of a MaxMind DB file
+func NewMMDBReader(buffer []byte) (*MMDBReader, error) {
+	markerIndex := bytes.LastIndex(buffer, mmdbMetadataMarker)
+	if markerIndex < 0 {
+		return nil, errors.New("mmdb: metadata marker not found")
+	}
+
+	metadataStart := markerIndex + len(mmdbMetadataMarker)
+	raw, _, err := decodeMMDB(buffer[metadataStart:], 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("mmdb: invalid metadata: %w", err)
+	}
+	metadata, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("mmdb: metadata is not a map")
+	}
+
+	r := &MMDBReader{
+		nodeCount:  metadataUint(metadata, "node_count"),
+		recordSize: metadataUint(metadata, "record_size"),
+		ipVersion:  metadataUint(metadata, "ip_version"),
+	}
+	r.DatabaseType, _ = metadata["database_type"].(string)
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("mmdb: unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("mmdb: unsupported ip version %d", r.ipVersion)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	dataStart := treeSize + mmdbDataSeparator
+	if dataStart > uint(markerIndex) {
+		return nil, errors.New("mmdb: search tree exceeds file size")
+	}
+	r.buffer = buffer
+	r.data = buffer[dataStart:markerIndex]
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// metadataUint reads an unsigned integer field from the metadata map
+func metadataUint(metadata map[string]interface{}, key string) uint {
+	switch v := metadata[key].(type) {
+	case uint16:
+		return uint(v)
+	case uint32:
+		return uint(v)
+	case uint64:
+		return uint(v)
+	}
+	return 0
+}
+
+// Lookup returns the record stored for ip, or nil when the address is not in the database
+func (r *MMDBReader) Lookup(ip net.IP) (map[string]interface{}, error) {
+	if ip == nil {
+		return nil, errors.New("mmdb: invalid IP address")
+	}
+
+	address := ip.To4()
+	node := uint(0)
+	if address != nil {
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else {
+		if r.ipVersion == 4 {
+			return nil, errors.New("mmdb: IPv6 address in an IPv4-only database")
+		}
+		address = ip.To16()
+	}
+
+	bitCount := len(address) * 8
+	for i := 0; i < bitCount && node < r.nodeCount; i++ {
+		bit := uint(address[i>>3]>>(7-uint(i%8))) & 1
+		node = r.readNode(node, bit)
+	}
+
+	if node == r.nodeCount {
+		return nil, nil // Address not in the database
+	}
+	if node < r.nodeCount {
+		return nil, errors.New("mmdb: invalid search tree")
+	}
+
+	offset := node - r.nodeCount - mmdbDataSeparator
+	value, _, err := decodeMMDB(r.data, offset, 0)
+	if err != nil {
+		return nil, err
+	}
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("mmdb: record is not a map")
+	}
+	return record, nil
+}
+
+// readNode returns the left (bit 0) or right (bit 1) record of a search tree node
+func (r *MMDBReader) readNode(node, bit uint) uint {
+	b := r.buffer[node*r.recordSize/4:]
+	switch r.recordSize {
+	case 24:
+		if bit == 0 {
+			return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3])<<16 | uint(b[4])<<8 | uint(b[5])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		if bit == 0 {
+			return uint(binary.BigEndian.Uint32(b[0:4]))
+		}
+		return uint(binary.BigEndian.Uint32(b[4:8]))
+	}
+}
+
+// maxMMDBDepth guards against maliciously nested or cyclic data
+const maxMMDBDepth = 32
+
+// decodeMMDB decodes the value at offset in a data section and returns it with the offset of the next field
+func decodeMMDB(data []byte, offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxMMDBDepth {
+		return nil, 0, errors.New("data nested too deeply")
+	}
+	if offset >= uint(len(data)) {
+		return nil, 0, errors.New("offset outside data section")
+	}
+
+	ctrl := data[offset]
+	offset++
+	fieldType := uint(ctrl >> 5)
+
+	if fieldType == mmdbPointer {
+		pointer, next, err := decodeMMDBPointer(data, ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := decodeMMDB(data, pointer, depth+1)
+		return value, next, err
+	}
+
+	if fieldType == mmdbExtended {
+		if offset >= uint(len(data)) {
+			return nil, 0, errors.New("truncated extended type")
+		}
+		fieldType = 7 + uint(data[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1F)
+	if size >= 29 {
+		extra := size - 28
+		if offset+extra > uint(len(data)) {
+			return nil, 0, errors.New("truncated field size")
+		}
+		n := uint(0)
+		for _, b := range data[offset : offset+extra] {
+			n = n<<8 | uint(b)
+		}
+		switch extra {
+		case 1:
+			size = 29 + n
+		case 2:
+			size = 285 + n
+		default:
+			size = 65821 + n
+		}
+		offset += extra
+	}
+
+	switch fieldType {
+	case mmdbMap:
+		result := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := decodeMMDB(data, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			keyString, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			value, next, err := decodeMMDB(data, next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			result[keyString] = value
+			offset = next
+		}
+		return result, offset, nil
+	case mmdbArray:
+		result := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := decodeMMDB(data, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			result = append(result, value)
+			offset = next
+		}
+		return result, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	case mmdbContainer, mmdbEndMarker:
+		return nil, offset, nil
+	}
+
+	if offset+size > uint(len(data)) {
+		return nil, 0, errors.New("field exceeds data section")
+	}
+	raw := data[offset : offset+size]
+	next := offset + size
+
+	switch fieldType {
+	case mmdbString:
+		return string(raw), next, nil
+	case mmdbBytes:
+		return append([]byte(nil), raw...), next, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, errors.New("invalid double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), next, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, errors.New("invalid float size")
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(raw)), next, nil
+	case mmdbUint16:
+		return uint16(decodeMMDBUint(raw)), next, nil
+	case mmdbUint32:
+		return uint32(decodeMMDBUint(raw)), next, nil
+	case mmdbUint64:
+		return decodeMMDBUint(raw), next, nil
+	case mmdbInt32:
+		return int32(uint32(decodeMMDBUint(raw))), next, nil
+	case mmdbUint128:
+		return new(big.Int).SetBytes(raw), next, nil
+	}
+	return nil, 0, fmt.Errorf("unknown field type %d", fieldType)
+}
+
+// decodeMMDBPointer resolves a pointer field to an offset in the data section
+func decodeMMDBPointer(data []byte, ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl>>3)&0x3 + 1
+	if offset+size > uint(len(data)) {
+		return 0, 0, errors.New("truncated pointer")
+	}
+
+	n := uint(0)
+	for _, b := range data[offset : offset+size] {
+		n = n<<8 | uint(b)
+	}
+	prefix := uint(ctrl & 0x7)
+
+	var pointer uint
+	switch size {
+	case 1:
+		pointer = prefix<<8 | n
+	case 2:
+		pointer = (prefix<<16 | n) + 2048
+	case 3:
+		pointer = (prefix<<24 | n) + 526336
+	default:
+		pointer = n
+	}
+	return pointer, offset + size, nil
+}
+
+// decodeMMDBUint decodes a big-endian unsigned integer of up to eight bytes
+func decodeMMDBUint(raw []byte) uint64 {
+	var n uint64
+	for _, b := range raw {
+		n = n<<8 | uint64(b)
+	}
+	return n
+}
@@ -0,0 +1,409 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mmdbValue encodes one field of a MaxMind DB data section
+type mmdbValue func(*bytes.Buffer)
+
+// writeMMDBControl writes a control byte, its extended type byte and any extra size bytes
+func writeMMDBControl(buf *bytes.Buffer, fieldType int, size int) {
+	var sizeBits byte
+	var extra []byte
+	switch {
+	case size < 29:
+		sizeBits = byte(size)
+	case size < 285:
+		sizeBits, extra = 29, []byte{byte(size - 29)}
+	default:
+		n := size - 285
+		sizeBits, extra = 30, []byte{byte(n >> 8), byte(n)}
+	}
+
+	if fieldType > 7 {
+		buf.WriteByte(sizeBits)
+		buf.WriteByte(byte(fieldType - 7))
+	} else {
+		buf.WriteByte(byte(fieldType)<<5 | sizeBits)
+	}
+	buf.Write(extra)
+}
+
+func mmdbStr(s string) mmdbValue {
+	return func(buf *bytes.Buffer) {
+		writeMMDBControl(buf, mmdbString, len(s))
+		buf.WriteString(s)
+	}
+}
+
+func mmdbUint(fieldType int, n uint64) mmdbValue {
+	return func(buf *bytes.Buffer) {
+		raw := make([]byte, 8)
+		binary.BigEndian.PutUint64(raw, n)
+		raw = bytes.TrimLeft(raw, "\x00")
+		writeMMDBControl(buf, fieldType, len(raw))
+		buf.Write(raw)
+	}
+}
+
+func mmdbRaw(fieldType int, raw []byte) mmdbValue {
+	return func(buf *bytes.Buffer) {
+		writeMMDBControl(buf, fieldType, len(raw))
+		buf.Write(raw)
+	}
+}
+
+func mmdbBoolean(v bool) mmdbValue {
+	return func(buf *bytes.Buffer) {
+		size := 0
+		if v {
+			size = 1
+		}
+		writeMMDBControl(buf, mmdbBool, size)
+	}
+}
+
+// mmdbPtr encodes a pointer using the one-byte form, which covers offsets below 2048
+func mmdbPtr(offset int) mmdbValue {
+	return func(buf *bytes.Buffer) {
+		buf.WriteByte(mmdbPointer<<5 | byte(offset>>8)&0x7)
+		buf.WriteByte(byte(offset))
+	}
+}
+
+// mmdbEntry is one key of an encoded map; keys are written in order
+type mmdbEntry struct {
+	key   string
+	value mmdbValue
+}
+
+func mmdbMapOf(entries ...mmdbEntry) mmdbValue {
+	return func(buf *bytes.Buffer) {
+		writeMMDBControl(buf, mmdbMap, len(entries))
+		for _, entry := range entries {
+			mmdbStr(entry.key)(buf)
+			entry.value(buf)
+		}
+	}
+}
+
+func mmdbArrayOf(values ...mmdbValue) mmdbValue {
+	return func(buf *bytes.Buffer) {
+		writeMMDBControl(buf, mmdbArray, len(values))
+		for _, value := range values {
+			value(buf)
+		}
+	}
+}
+
+// mmdbNetwork maps a CIDR range to the data section offset of its record
+type mmdbNetwork struct {
+	cidr   string
+	offset int
+}
+
+// buildMMDB assembles a database file: search tree, separator, data section and metadata
+// IPv4 ranges in an IPv6 database are stored under ::/96 like the GeoLite2 files
+func buildMMDB(t *testing.T, ipVersion, recordSize int, data []byte, networks []mmdbNetwork) []byte {
+	t.Helper()
+
+	const (
+		empty   = -1
+		dataRef = -2
+	)
+	type record struct {
+		kind   int // Node index, empty or dataRef
+		offset int
+	}
+	nodes := [][2]record{{{kind: empty}, {kind: empty}}}
+
+	for _, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prefix, _ := ipNet.Mask.Size()
+		address := []byte(ipNet.IP)
+		if ipVersion == 6 {
+			if v4 := ipNet.IP.To4(); v4 != nil {
+				address = append(make([]byte, 12), v4...)
+				prefix += 96
+			} else {
+				address = ipNet.IP.To16()
+			}
+		}
+
+		node := 0
+		for i := 0; i < prefix; i++ {
+			bit := address[i/8] >> (7 - uint(i%8)) & 1
+			if i == prefix-1 {
+				nodes[node][bit] = record{kind: dataRef, offset: network.offset}
+				break
+			}
+			if nodes[node][bit].kind == empty {
+				nodes = append(nodes, [2]record{{kind: empty}, {kind: empty}})
+				nodes[node][bit] = record{kind: len(nodes) - 1}
+			}
+			node = nodes[node][bit].kind
+		}
+	}
+
+	nodeCount := len(nodes)
+	value := func(r record) uint32 {
+		switch r.kind {
+		case empty:
+			return uint32(nodeCount)
+		case dataRef:
+			return uint32(nodeCount + mmdbDataSeparator + r.offset)
+		}
+		return uint32(r.kind)
+	}
+
+	var file bytes.Buffer
+	for _, node := range nodes {
+		left, right := value(node[0]), value(node[1])
+		switch recordSize {
+		case 24:
+			file.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			file.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left),
+				byte(left>>24)<<4 | byte(right>>24)&0x0F,
+				byte(right >> 16), byte(right >> 8), byte(right)})
+		default:
+			binary.Write(&file, binary.BigEndian, [2]uint32{left, right})
+		}
+	}
+	file.Write(make([]byte, mmdbDataSeparator))
+	file.Write(data)
+	file.Write(mmdbMetadataMarker)
+	mmdbMapOf(
+		mmdbEntry{"binary_format_major_version", mmdbUint(mmdbUint16, 2)},
+		mmdbEntry{"database_type", mmdbStr("Test-City")},
+		mmdbEntry{"ip_version", mmdbUint(mmdbUint16, uint64(ipVersion))},
+		mmdbEntry{"node_count", mmdbUint(mmdbUint32, uint64(nodeCount))},
+		mmdbEntry{"record_size", mmdbUint(mmdbUint16, uint64(recordSize))},
+	)(&file)
+	return file.Bytes()
+}
+
+// cityFixture returns a data section with two city records sharing one country map through a pointer
+func cityFixture() (data []byte, bangalore, mumbai int) {
+	var buf bytes.Buffer
+	country := buf.Len()
+	mmdbMapOf(mmdbEntry{"iso_code", mmdbStr("IN")})(&buf)
+
+	bangalore = buf.Len()
+	mmdbMapOf(
+		mmdbEntry{"city", mmdbMapOf(mmdbEntry{"names", mmdbMapOf(mmdbEntry{"en", mmdbStr("Bengaluru")})})},
+		mmdbEntry{"country", mmdbPtr(country)},
+		mmdbEntry{"subdivisions", mmdbArrayOf(mmdbMapOf(mmdbEntry{"names", mmdbMapOf(mmdbEntry{"en", mmdbStr("Karnataka")})}))},
+	)(&buf)
+
+	mumbai = buf.Len()
+	mmdbMapOf(
+		mmdbEntry{"city", mmdbMapOf(mmdbEntry{"names", mmdbMapOf(mmdbEntry{"en", mmdbStr("Mumbai")})})},
+		mmdbEntry{"country", mmdbPtr(country)},
+	)(&buf)
+
+	return buf.Bytes(), bangalore, mumbai
+}
+
+func cityName(t *testing.T, record map[string]interface{}) string {
+	t.Helper()
+	city, _ := record["city"].(map[string]interface{})
+	names, _ := city["names"].(map[string]interface{})
+	name, _ := names["en"].(string)
+	return name
+}
+
+func TestMMDBLookup(t *testing.T) {
+	data, bangalore, mumbai := cityFixture()
+
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			networks := []mmdbNetwork{
+				{"203.0.113.0/24", bangalore},
+				{"198.51.100.128/25", mumbai},
+			}
+			if ipVersion == 6 {
+				networks = append(networks, mmdbNetwork{"2001:db8::/32", mumbai})
+			}
+
+			reader, err := NewMMDBReader(buildMMDB(t, ipVersion, recordSize, data, networks))
+			if err != nil {
+				t.Fatalf("ipv%d/%d: NewMMDBReader: %v", ipVersion, recordSize, err)
+			}
+			if reader.DatabaseType != "Test-City" {
+				t.Errorf("ipv%d/%d: DatabaseType = %q", ipVersion, recordSize, reader.DatabaseType)
+			}
+
+			lookups := map[string]string{
+				"203.0.113.1":    "Bengaluru",
+				"203.0.113.255":  "Bengaluru",
+				"198.51.100.200": "Mumbai",
+				"198.51.100.1":   "", // Outside the /25
+				"192.0.2.1":      "",
+			}
+			if ipVersion == 6 {
+				lookups["2001:db8::1"] = "Mumbai"
+				lookups["::ffff:203.0.113.9"] = "Bengaluru"
+				lookups["2001:db9::1"] = ""
+			}
+			for address, want := range lookups {
+				record, err := reader.Lookup(net.ParseIP(address))
+				if err != nil {
+					t.Errorf("ipv%d/%d: Lookup(%s): %v", ipVersion, recordSize, address, err)
+					continue
+				}
+				if want == "" {
+					if record != nil {
+						t.Errorf("ipv%d/%d: Lookup(%s) = %v, want no record", ipVersion, recordSize, address, record)
+					}
+					continue
+				}
+				if got := cityName(t, record); got != want {
+					t.Errorf("ipv%d/%d: Lookup(%s) city = %q, want %q", ipVersion, recordSize, address, got, want)
+				}
+				country, _ := record["country"].(map[string]interface{})
+				if country["iso_code"] != "IN" {
+					t.Errorf("ipv%d/%d: Lookup(%s) country = %v, want IN via pointer", ipVersion, recordSize, address, country)
+				}
+			}
+		}
+	}
+}
+
+func TestMMDBLookupSubdivisions(t *testing.T) {
+	data, bangalore, _ := cityFixture()
+	reader, err := NewMMDBReader(buildMMDB(t, 6, 28, data, []mmdbNetwork{{"203.0.113.0/24", bangalore}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := reader.Lookup(net.ParseIP("203.0.113.7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subdivisions, ok := record["subdivisions"].([]interface{})
+	if !ok || len(subdivisions) != 1 {
+		t.Fatalf("subdivisions = %#v", record["subdivisions"])
+	}
+	want := map[string]interface{}{"names": map[string]interface{}{"en": "Karnataka"}}
+	if !reflect.DeepEqual(subdivisions[0], want) {
+		t.Errorf("subdivisions[0] = %#v, want %#v", subdivisions[0], want)
+	}
+}
+
+func TestMMDBLookupIPv6InIPv4Database(t *testing.T) {
+	data, bangalore, _ := cityFixture()
+	reader, err := NewMMDBReader(buildMMDB(t, 4, 24, data, []mmdbNetwork{{"203.0.113.0/24", bangalore}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Lookup(net.ParseIP("2001:db8::1")); err == nil {
+		t.Error("Lookup of an IPv6 address in an IPv4 database succeeded")
+	}
+	if _, err := reader.Lookup(nil); err == nil {
+		t.Error("Lookup(nil) succeeded")
+	}
+}
+
+func TestNewMMDBReaderRejectsInvalidFiles(t *testing.T) {
+	data, bangalore, _ := cityFixture()
+	valid := buildMMDB(t, 4, 24, data, []mmdbNetwork{{"203.0.113.0/24", bangalore}})
+
+	badRecordSize := bytes.Replace(valid, []byte("record_size\xa1\x18"), []byte("record_size\xa1\x10"), 1)
+	if bytes.Equal(badRecordSize, valid) {
+		t.Fatal("fixture metadata layout changed")
+	}
+
+	tests := map[string][]byte{
+		"empty":              nil,
+		"no marker":          []byte("not a database"),
+		"record size":        badRecordSize,
+		"truncated metadata": valid[:len(valid)-3],
+	}
+	for name, file := range tests {
+		if _, err := NewMMDBReader(file); err == nil {
+			t.Errorf("%s: NewMMDBReader succeeded", name)
+		}
+	}
+}
+
+func TestDecodeMMDBTypes(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	double := make([]byte, 8)
+	binary.BigEndian.PutUint64(double, math.Float64bits(12.5))
+	float := make([]byte, 4)
+	binary.BigEndian.PutUint32(float, math.Float32bits(-1.5))
+
+	tests := []struct {
+		name  string
+		value mmdbValue
+		want  interface{}
+	}{
+		{"short string", mmdbStr("Karnataka"), "Karnataka"},
+		{"long string", mmdbStr(long), long},
+		{"double", mmdbRaw(mmdbDouble, double), 12.5},
+		{"float", mmdbRaw(mmdbFloat, float), float32(-1.5)},
+		{"bytes", mmdbRaw(mmdbBytes, []byte{1, 2}), []byte{1, 2}},
+		{"uint16", mmdbUint(mmdbUint16, 443), uint16(443)},
+		{"uint32", mmdbUint(mmdbUint32, 1<<20), uint32(1 << 20)},
+		{"uint64", mmdbUint(mmdbUint64, 1<<40), uint64(1 << 40)},
+		{"zero uint32", mmdbUint(mmdbUint32, 0), uint32(0)},
+		{"int32", mmdbRaw(mmdbInt32, []byte{0xFF, 0xFF, 0xFF, 0xFE}), int32(-2)},
+		{"uint128", mmdbRaw(mmdbUint128, []byte{1, 0, 0, 0, 0, 0, 0, 0, 0}), new(big.Int).Lsh(big.NewInt(1), 64)},
+		{"true", mmdbBoolean(true), true},
+		{"false", mmdbBoolean(false), false},
+		{"array", mmdbArrayOf(mmdbStr("a"), mmdbUint(mmdbUint16, 1)), []interface{}{"a", uint16(1)}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		tt.value(&buf)
+		got, next, err := decodeMMDB(buf.Bytes(), 0, 0)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
+		}
+		if next != uint(buf.Len()) {
+			t.Errorf("%s: next offset = %d, want %d", tt.name, next, buf.Len())
+		}
+	}
+}
+
+func TestDecodeMMDBRejectsMalformedData(t *testing.T) {
+	var cyclic bytes.Buffer
+	mmdbPtr(0)(&cyclic)
+
+	var truncated bytes.Buffer
+	mmdbStr("Bengaluru")(&truncated)
+
+	var badKey bytes.Buffer
+	writeMMDBControl(&badKey, mmdbMap, 1)
+	mmdbUint(mmdbUint16, 1)(&badKey)
+	mmdbStr("value")(&badKey)
+
+	tests := map[string][]byte{
+		"cyclic pointer":   cyclic.Bytes(),
+		"truncated string": truncated.Bytes()[:4],
+		"non-string key":   badKey.Bytes(),
+		"bad double size":  {mmdbDouble<<5 | 4, 0, 0, 0, 0},
+		"empty":            {},
+	}
+	for name, data := range tests {
+		if _, _, err := decodeMMDB(data, 0, 0); err == nil {
+			t.Errorf("%s: decodeMMDB succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxReferrerLength bounds the stored full referrer URL
+const maxReferrerLength = 2048
+
+// maxReferrerDomainLength matches the referrer_domain column; longer hosts are not real domains
+const maxReferrerDomainLength = 255
+
+// NormalizeReferrer splits a Referer header into a referring domain and a cleaned full URL
+// The domain is lowercased with any port and leading "www." removed; both values are
+// empty for direct traffic or when the header is not an absolute http(s) URL, and the
+// domain is dropped when it is too long to be a real host name
+func NormalizeReferrer(raw string) (domain string, full string) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", ""
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return "", ""
+	}
+
+	domain = strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	if utf8.RuneCountInString(domain) > maxReferrerDomainLength {
+		domain = ""
+	}
+
+	// Drop credentials and fragments; they are never useful for attribution
+	parsed.User = nil
+	parsed.Fragment = ""
+	full = parsed.String()
+	if len(full) > maxReferrerLength {
+		full = full[:maxReferrerLength]
+	}
+
+	return domain, full
+}
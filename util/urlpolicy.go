@@ -0,0 +1,223 @@
+package util
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Codes reported in URLValidationError.Code
+const (
+	URLErrorRequired          = "url_required"
+	URLErrorTooLong           = "url_too_long"
+	URLErrorInvalid           = "url_invalid"
+	URLErrorUnsupportedScheme = "url_unsupported_scheme"
+	URLErrorCredentials       = "url_credentials_not_allowed"
+	URLErrorInvalidHost       = "url_invalid_host"
+	URLErrorPrivateAddress    = "url_private_address"
+	URLErrorSelfReferential   = "url_self_referential"
+	URLErrorBlocklisted       = "url_blocklisted"
+)
+
+// URLValidationError explains why a destination URL was rejected
+type URLValidationError struct {
+	Code    string // One of the URLError constants
+	Message string
+}
+
+func (e *URLValidationError) Error() string {
+	return e.Message
+}
+
+// URLPolicy normalises and validates link destinations
+type URLPolicy struct {
+	MaxLength    int      // Longest accepted normalised URL
+	BlockedHosts []string // Our own short link domains; links to them or their subdomains would redirect in a loop
+}
+
+// schemePattern matches a leading URL scheme such as "https:" or "javascript:"
+var schemePattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+.-]*):`)
+
+// hostProfile converts internationalised host names to punycode; unlike idna.Lookup it accepts
+// underscores, which some real subdomains use
+var hostProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false))
+
+// numericHostPattern matches hosts that browsers read as IPv4 addresses in decimal, octal or hex notation
+var numericHostPattern = regexp.MustCompile(`^(0x[0-9a-f]+|[0-9]+)(\.(0x[0-9a-f]+|[0-9]+)){0,3}$`)
+
+// Normalize turns a caller-supplied destination into the canonical form stored for a link
+//
+// A missing scheme defaults to https; only http and https are accepted. The host is lowercased,
+// converted to punycode and stripped of a trailing dot and default port, an empty path becomes "/"
+// and an empty query or fragment is dropped. Credentials, private, loopback and link-local
+// addresses and our own domains are rejected.
+func (p URLPolicy) Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", &URLValidationError{Code: URLErrorRequired, Message: "url is required"}
+	}
+	if p.MaxLength > 0 && len(raw) > p.MaxLength*4 {
+		return "", p.tooLong()
+	}
+
+	if match := schemePattern.FindStringSubmatch(raw); match != nil && !strings.Contains(raw, "://") {
+		// "example.com:8080/path" has no scheme; "javascript:..." and "mailto:..." do
+		if rest := raw[len(match[0]):]; rest == "" || rest[0] < '0' || rest[0] > '9' {
+			return "", unsupportedScheme(match[1])
+		}
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", &URLValidationError{Code: URLErrorInvalid, Message: "url is not a valid URL"}
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", unsupportedScheme(parsed.Scheme)
+	}
+	if parsed.User != nil {
+		return "", &URLValidationError{Code: URLErrorCredentials, Message: "url must not contain a username or password"}
+	}
+
+	host, err := p.normalizeHost(parsed)
+	if err != nil {
+		return "", err
+	}
+	parsed.Host = host
+
+	if parsed.Path == "" {
+		parsed.Path = "/"
+		parsed.RawPath = ""
+	}
+	parsed.ForceQuery = false
+	parsed.Opaque = ""
+
+	normalized := parsed.String()
+	if p.MaxLength > 0 && len(normalized) > p.MaxLength {
+		return "", p.tooLong()
+	}
+	return normalized, nil
+}
+
+// normalizeHost validates the host and port of a parsed URL and returns them in canonical form
+func (p URLPolicy) normalizeHost(parsed *url.URL) (string, error) {
+	hostname := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if hostname == "" {
+		return "", &URLValidationError{Code: URLErrorInvalidHost, Message: "url must include a host"}
+	}
+
+	port := parsed.Port()
+	if port != "" {
+		number, err := strconv.Atoi(port)
+		if err != nil || number < 1 || number > 65535 {
+			return "", &URLValidationError{Code: URLErrorInvalidHost, Message: "url has an invalid port"}
+		}
+		if (parsed.Scheme == "http" && number == 80) || (parsed.Scheme == "https" && number == 443) {
+			port = ""
+		} else {
+			port = strconv.Itoa(number)
+		}
+	}
+
+	if ip := net.ParseIP(hostname); ip != nil {
+		if isNonPublicIP(ip) {
+			return "", &URLValidationError{Code: URLErrorPrivateAddress, Message: "url must not point to a private, loopback or link-local address"}
+		}
+		if ip.To4() == nil {
+			hostname = "[" + ip.String() + "]"
+		} else {
+			hostname = ip.String()
+		}
+	} else {
+		if numericHostPattern.MatchString(hostname) {
+			return "", &URLValidationError{Code: URLErrorInvalidHost, Message: "url host is not a valid domain or IP address"}
+		}
+
+		ascii, err := hostProfile.ToASCII(hostname)
+		if err != nil {
+			return "", &URLValidationError{Code: URLErrorInvalidHost, Message: "url host is not a valid domain name"}
+		}
+		hostname = ascii
+
+		if hostname == "localhost" || strings.HasSuffix(hostname, ".localhost") {
+			return "", &URLValidationError{Code: URLErrorPrivateAddress, Message: "url must not point to a private, loopback or link-local address"}
+		}
+		for _, blocked := range p.BlockedHosts {
+			if hostname == blocked || strings.HasSuffix(hostname, "."+blocked) {
+				return "", &URLValidationError{Code: URLErrorSelfReferential, Message: "url must not point to another short link"}
+			}
+		}
+	}
+
+	if port != "" {
+		return hostname + ":" + port, nil
+	}
+	return hostname, nil
+}
+
+// tooLong reports a URL over the policy's length limit
+func (p URLPolicy) tooLong() error {
+	return &URLValidationError{Code: URLErrorTooLong, Message: "url must be at most " + strconv.Itoa(p.MaxLength) + " characters"}
+}
+
+// unsupportedScheme reports a URL whose scheme is not http or https
+func unsupportedScheme(scheme string) error {
+	return &URLValidationError{Code: URLErrorUnsupportedScheme, Message: "url scheme " + strconv.Quote(strings.ToLower(scheme)) + " is not allowed; use http or https"}
+}
+
+// specialPurposeNetworks are IANA special-purpose ranges that are not reachable as public
+// destinations but are not covered by the net.IP classification methods
+var specialPurposeNetworks = parseNetworks(
+	"0.0.0.0/8",       // "This network"
+	"100.64.0.0/10",   // Carrier-grade NAT shared address space
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // Documentation (TEST-NET-1)
+	"198.18.0.0/15",   // Benchmarking
+	"198.51.100.0/24", // Documentation (TEST-NET-2)
+	"203.0.113.0/24",  // Documentation (TEST-NET-3)
+	"240.0.0.0/4",     // Reserved, including the limited broadcast address
+	"::/96",           // IPv4-compatible addresses
+	"64:ff9b::/96",    // NAT64, which can embed private IPv4 addresses
+	"64:ff9b:1::/48",  // Local-use NAT64
+	"100::/64",        // Discard-only
+	"2001::/23",       // IETF protocol assignments, including Teredo
+	"2001:db8::/32",   // Documentation
+	"2002::/16",       // 6to4, which can embed private IPv4 addresses
+	"fec0::/10",       // Deprecated site-local
+)
+
+// parseNetworks parses CIDR literals, panicking on an invalid one
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// isNonPublicIP reports whether an address is private, loopback, link-local, unspecified, multicast
+// or in another special-purpose range such as carrier-grade NAT
+func isNonPublicIP(ip net.IP) bool {
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range specialPurposeNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
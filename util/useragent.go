@@ -0,0 +1,154 @@
+package util
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Device classes reported by ParseUserAgent
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+)
+
+// MaxBrowserLength is the size of the url_visits.browser column, which also holds the names
+// of custom bot rules
+const MaxBrowserLength = 50
+
+// TruncateRunes cuts s to at most n characters without splitting a multibyte character
+func TruncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// UserAgentInfo is the parsed form of a User-Agent header
+type UserAgentInfo struct {
+	Browser    string
+	OS         string
+	DeviceType string
+	IsBot      bool
+}
+
+// uaRule maps a User-Agent substring (lowercase) to a reported name
+// Rules are checked in order, so more specific tokens must come first
+type uaRule struct {
+	token string
+	name  string
+}
+
+// botTokens identifies crawlers, link-preview unfurlers, monitors and scripted clients
+var botTokens = []uaRule{
+	{"slackbot", "Slackbot"},
+	{"slack-imgproxy", "Slackbot"},
+	{"twitterbot", "Twitterbot"},
+	{"whatsapp", "WhatsApp"},
+	{"facebookexternalhit", "Facebook"},
+	{"facebookcatalog", "Facebook"},
+	{"linkedinbot", "LinkedInBot"},
+	{"telegrambot", "TelegramBot"},
+	{"discordbot", "Discordbot"},
+	{"skypeuripreview", "Skype"},
+	{"microsoftpreview", "Microsoft Preview"},
+	{"google-pagerenderer", "Google Page Renderer"},
+	{"embedly", "Embedly"},
+	{"iframely", "Iframely"},
+	{"vkshare", "VK"},
+	{"mastodon/", "Mastodon"},
+	{"googlebot", "Googlebot"},
+	{"bingbot", "Bingbot"},
+	{"yandexbot", "YandexBot"},
+	{"duckduckbot", "DuckDuckBot"},
+	{"baiduspider", "Baiduspider"},
+	{"applebot", "Applebot"},
+	{"uptimerobot", "UptimeRobot"},
+	{"pingdom", "Pingdom"},
+	{"headlesschrome", "HeadlessChrome"},
+	{"curl/", "curl"},
+	{"wget/", "Wget"},
+	{"python-requests", "python-requests"},
+	{"go-http-client", "Go-http-client"},
+	{"bot", "Other bot"},
+	{"crawler", "Other bot"},
+	{"spider", "Other bot"},
+}
+
+var browserTokens = []uaRule{
+	{"edg/", "Edge"},
+	{"edge/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser", "Samsung Internet"},
+	{"yabrowser", "Yandex Browser"},
+	{"firefox/", "Firefox"},
+	{"fxios", "Firefox"},
+	{"crios", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"chromium", "Chrome"},
+	{"msie", "Internet Explorer"},
+	{"trident/", "Internet Explorer"},
+	{"safari/", "Safari"},
+}
+
+var osTokens = []uaRule{
+	{"windows phone", "Windows Phone"},
+	{"windows", "Windows"},
+	{"iphone", "iOS"},
+	{"ipad", "iPadOS"},
+	{"ipod", "iOS"},
+	{"android", "Android"},
+	{" cros ", "ChromeOS"}, // "; CrOS x86_64"; the spaces keep it from matching inside "Microsoft"
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"linux", "Linux"},
+}
+
+// matchRule returns the name of the first rule whose token occurs in ua
+func matchRule(ua string, rules []uaRule) (string, bool) {
+	for _, rule := range rules {
+		if strings.Contains(ua, rule.token) {
+			return rule.name, true
+		}
+	}
+	return "", false
+}
+
+// ParseUserAgent classifies a User-Agent header into browser family, OS, device class and bot flag
+// Unrecognised values are reported as "Other"; an empty header is treated as a bot since
+// real browsers always send one
+func ParseUserAgent(userAgent string) UserAgentInfo {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" {
+		return UserAgentInfo{Browser: "Other", OS: "Other", DeviceType: DeviceBot, IsBot: true}
+	}
+
+	info := UserAgentInfo{Browser: "Other", OS: "Other", DeviceType: DeviceDesktop}
+
+	if name, ok := matchRule(ua, osTokens); ok {
+		info.OS = name
+	}
+
+	if name, ok := matchRule(ua, botTokens); ok {
+		info.Browser = name
+		info.DeviceType = DeviceBot
+		info.IsBot = true
+		return info
+	}
+
+	if name, ok := matchRule(ua, browserTokens); ok {
+		info.Browser = name
+	}
+
+	switch {
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") ||
+		(strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		info.DeviceType = DeviceTablet
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "ipod"):
+		info.DeviceType = DeviceMobile
+	}
+
+	return info
+}
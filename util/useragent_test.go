@@ -0,0 +1,37 @@
+package util
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+	tests := map[string]UserAgentInfo{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0": {
+			Browser: "Edge", OS: "Windows", DeviceType: DeviceDesktop,
+		},
+		"Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36": {
+			Browser: "Chrome", OS: "ChromeOS", DeviceType: DeviceDesktop,
+		},
+		"Microsoft Office/16.0 (Microsoft Outlook Mail 16.0.17029; Pro; Macintosh; Mac OS X 14.1)": {
+			Browser: "Other", OS: "macOS", DeviceType: DeviceDesktop,
+		},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91": {
+			Browser: "Edge", OS: "macOS", DeviceType: DeviceDesktop,
+		},
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1": {
+			Browser: "Safari", OS: "iOS", DeviceType: DeviceMobile,
+		},
+		"Mozilla/5.0 (Linux; Android 14; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36": {
+			Browser: "Chrome", OS: "Android", DeviceType: DeviceTablet,
+		},
+		"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)": {
+			Browser: "Slackbot", OS: "Other", DeviceType: DeviceBot, IsBot: true,
+		},
+		"": {
+			Browser: "Other", OS: "Other", DeviceType: DeviceBot, IsBot: true,
+		},
+	}
+	for userAgent, want := range tests {
+		if got := ParseUserAgent(userAgent); got != want {
+			t.Errorf("ParseUserAgent(%q) = %+v, want %+v", userAgent, got, want)
+		}
+	}
+}